@@ -0,0 +1,195 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/sts"
+)
+
+// awsAccount is the configuration loaded for one profile
+type awsAccount struct {
+	// Profile is the shared config profile, empty for the default credential chain
+	Profile string
+	// AccountID is looked up when listing several profiles, empty if unknown
+	AccountID string
+	Config    aws.Config
+}
+
+// accounts holds the configuration for every --profile, in flag order
+var accounts []awsAccount
+
+// awsProfiles returns the profiles given with --profile and --profiles,
+// without duplicates. An empty list means the default credential chain.
+func awsProfiles() []string {
+	seen := make(map[string]bool)
+	var names []string
+	for _, p := range append(append([]string{}, profiles...), profileList...) {
+		if p != "" && !seen[p] {
+			seen[p] = true
+			names = append(names, p)
+		}
+	}
+	return names
+}
+
+// multiAccount reports whether listings span more than one profile, in which
+// case the profile and account are shown next to every instance
+func multiAccount() bool {
+	return len(accounts) > 1
+}
+
+// lookupAccountIDs fills in the account ID of every account concurrently.
+// Failures only cost the ID, since the listing reports its own errors.
+func lookupAccountIDs(ctx context.Context, accts []awsAccount) {
+	var wg sync.WaitGroup
+	for i := range accts {
+		wg.Add(1)
+		go func(acct *awsAccount) {
+			defer wg.Done()
+			output, err := sts.NewFromConfig(acct.Config).GetCallerIdentity(ctx, &sts.GetCallerIdentityInput{})
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "Warning: could not look up the account for profile %s: %v\n", acct.Profile, err)
+				return
+			}
+			acct.AccountID = aws.ToString(output.Account)
+		}(&accts[i])
+	}
+	wg.Wait()
+}
+
+// accountConfig returns the configuration of profile's account, targeting
+// region. Unknown profiles fall back to cfg.
+func accountConfig(cfg aws.Config, profile, region string) aws.Config {
+	for _, acct := range accounts {
+		if acct.Profile == profile {
+			cfg = acct.Config
+			break
+		}
+	}
+	return regionConfig(cfg, region)
+}
+
+// accountLabel names an account by profile and, when known, account ID
+func accountLabel(profile, accountID string) string {
+	if profile == "" {
+		profile = "default"
+	}
+	if accountID == "" {
+		return profile
+	}
+	return fmt.Sprintf("%s (%s)", profile, accountID)
+}
+
+// locationColumns returns the extra tab-separated table columns that tell
+// instances apart when listings span several profiles or regions
+func locationColumns(profile, accountID, region string) string {
+	var b strings.Builder
+	if multiAccount() {
+		fmt.Fprintf(&b, "\t%s", accountLabel(profile, accountID))
+	}
+	if multiRegion() {
+		fmt.Fprintf(&b, "\t%s", region)
+	}
+	return b.String()
+}
+
+// locationLabel describes where an instance lives when listings span several
+// profiles or regions, and is empty otherwise
+func locationLabel(profile, region string) string {
+	switch {
+	case multiAccount() && multiRegion():
+		return profile + "/" + region
+	case multiAccount():
+		return profile
+	case multiRegion():
+		return region
+	}
+	return ""
+}
+
+// listAcrossAccounts runs list for every target region of every account, at
+// most regionConcurrency at a time, and returns the results in profile and
+// region order. The account passed to list has its Config set to the region.
+// kind and filters name the cached listing list returns, see targetRegions.
+// A profile or region that fails is reported on stderr and skipped; the
+// listing only fails when every one does.
+func listAcrossAccounts[T any](ctx context.Context, cfg aws.Config, what, kind string, filters []string, list func(context.Context, awsAccount) ([]T, error)) ([]T, error) {
+	accts := accounts
+	if len(accts) == 0 {
+		accts = []awsAccount{{Config: cfg}}
+	}
+
+	var targets []awsAccount
+	for _, acct := range accts {
+		regions, err := targetRegions(ctx, acct, kind, filters)
+		if err != nil {
+			if len(accts) == 1 {
+				return nil, err
+			}
+			fmt.Fprintf(os.Stderr, "Warning: skipping profile %s: %v\n", acct.Profile, err)
+			continue
+		}
+		for _, r := range regions {
+			target := acct
+			target.Config = regionConfig(acct.Config, r)
+			targets = append(targets, target)
+		}
+	}
+
+	switch len(targets) {
+	case 0:
+		return nil, fmt.Errorf("failed to list %s in any profile", what)
+	case 1:
+		// A single profile and region behaves exactly as before, errors included
+		return list(ctx, targets[0])
+	}
+
+	results := make([][]T, len(targets))
+	errs := make([]error, len(targets))
+
+	var wg sync.WaitGroup
+	sem := make(chan struct{}, regionConcurrency)
+	for i, target := range targets {
+		wg.Add(1)
+		go func(i int, target awsAccount) {
+			defer wg.Done()
+			sem <- struct{}{}
+			defer func() { <-sem }()
+			results[i], errs[i] = list(ctx, target)
+		}(i, target)
+	}
+	wg.Wait()
+
+	var all []T
+	var lastErr error
+	failed := 0
+	for i, target := range targets {
+		if errs[i] != nil {
+			where := target.Config.Region
+			if len(accts) > 1 {
+				where = target.Profile + "/" + where
+			}
+			fmt.Fprintf(os.Stderr, "Warning: skipping %s in %s: %v\n", what, where, errs[i])
+			lastErr = errs[i]
+			failed++
+			continue
+		}
+		all = append(all, results[i]...)
+	}
+
+	if failed == len(targets) {
+		return nil, fmt.Errorf("failed to list %s in any region: %w", what, lastErr)
+	}
+
+	all, truncated := truncateResults(all, false)
+	if truncated {
+		warnTruncated(what)
+	}
+
+	return all, nil
+}
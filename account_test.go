@@ -0,0 +1,123 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+)
+
+func TestAWSProfiles(t *testing.T) {
+	defer func() { profiles, profileList = nil, nil }()
+
+	profiles = nil
+	profileList = nil
+	if got := awsProfiles(); got != nil {
+		t.Errorf("Expected no profiles, got %v", got)
+	}
+
+	profiles = []string{"dev", "prod"}
+	profileList = []string{"staging", "dev", ""}
+	if got := awsProfiles(); !reflect.DeepEqual(got, []string{"dev", "prod", "staging"}) {
+		t.Errorf("Expected dev, prod, staging, got %v", got)
+	}
+}
+
+func TestAccountConfig(t *testing.T) {
+	defer func() { accounts = nil }()
+
+	accounts = []awsAccount{
+		{Profile: "dev", Config: aws.Config{Region: "us-east-1", AppID: "dev"}},
+		{Profile: "prod", Config: aws.Config{Region: "us-east-1", AppID: "prod"}},
+	}
+	fallback := aws.Config{Region: "us-east-1", AppID: "fallback"}
+
+	if got := accountConfig(fallback, "prod", "eu-west-1"); got.AppID != "prod" || got.Region != "eu-west-1" {
+		t.Errorf("Expected the prod config in eu-west-1, got %s in %s", got.AppID, got.Region)
+	}
+	if got := accountConfig(fallback, "unknown", ""); got.AppID != "fallback" || got.Region != "us-east-1" {
+		t.Errorf("Expected the fallback config, got %s in %s", got.AppID, got.Region)
+	}
+}
+
+func TestLocationLabel(t *testing.T) {
+	defer func() { accounts, regionList = nil, nil }()
+
+	tests := []struct {
+		name     string
+		accounts int
+		regions  []string
+		want     string
+	}{
+		{"single account and region", 1, nil, ""},
+		{"several regions", 1, []string{"us-east-1", "eu-west-1"}, "eu-west-1"},
+		{"several accounts", 2, nil, "prod"},
+		{"several accounts and regions", 2, []string{"us-east-1", "eu-west-1"}, "prod/eu-west-1"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			accounts = make([]awsAccount, tt.accounts)
+			regionList = tt.regions
+			if got := locationLabel("prod", "eu-west-1"); got != tt.want {
+				t.Errorf("Expected %q, got %q", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestListAcrossAccounts(t *testing.T) {
+	defer func() { accounts, regionList, maxResults = nil, nil, 0 }()
+
+	list := func(ctx context.Context, acct awsAccount) ([]Instance, error) {
+		switch acct.Config.Region {
+		case "broken-1":
+			return nil, errors.New("access denied")
+		case "empty-1":
+			return nil, nil
+		}
+		return []Instance{{ID: "i-1", Profile: acct.Profile, Region: acct.Config.Region}}, nil
+	}
+
+	tests := []struct {
+		name     string
+		profiles []string
+		regions  []string
+		max      int
+		want     []string
+		wantErr  bool
+	}{
+		{"results in region order", nil, []string{"us-west-2", "eu-west-1"}, 0, []string{"/us-west-2", "/eu-west-1"}, false},
+		{"results in profile order", []string{"dev", "prod"}, []string{"us-west-2", "eu-west-1"}, 0, []string{"dev/us-west-2", "dev/eu-west-1", "prod/us-west-2", "prod/eu-west-1"}, false},
+		{"failed region is skipped", nil, []string{"broken-1", "eu-west-1"}, 0, []string{"/eu-west-1"}, false},
+		{"empty region is not a failure", nil, []string{"broken-1", "empty-1"}, 0, nil, false},
+		{"every region failing is an error", []string{"dev", "prod"}, []string{"broken-1"}, 0, nil, true},
+		{"max results applies to the total", []string{"dev", "prod"}, []string{"us-west-2"}, 1, []string{"dev/us-west-2"}, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			accounts = nil
+			for _, p := range tt.profiles {
+				accounts = append(accounts, awsAccount{Profile: p})
+			}
+			regionList = tt.regions
+			maxResults = tt.max
+
+			instances, err := listAcrossAccounts(context.Background(), aws.Config{}, "EC2 instances", cacheKindEC2, nil, list)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Expected error %v, got %v", tt.wantErr, err)
+			}
+
+			var got []string
+			for _, inst := range instances {
+				got = append(got, inst.Profile+"/"+inst.Region)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Expected %v, got %v", tt.want, got)
+			}
+		})
+	}
+}
@@ -0,0 +1,143 @@
+package main
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+)
+
+// cacheVersion is part of every cache key; bump it when the cached structs change
+const cacheVersion = 3
+
+// Cached listing kinds
+const (
+	cacheKindEC2 = "ec2"
+	cacheKindRDS = "rds"
+)
+
+// cacheFile is the on-disk form of one cached listing
+type cacheFile[T any] struct {
+	FetchedAt time.Time `json:"fetchedAt"`
+	Items     []T       `json:"items"`
+}
+
+func cacheDir() string {
+	return filepath.Join(appDataDir(), "cache")
+}
+
+// cachePath returns the cache file for a listing of kind in one account and
+// region. Filters and --max-results are part of the key, since they change
+// the results. The region is kept readable at the end of the name, so the
+// cached regions can be found with cachedRegions.
+func cachePath(kind, profile, region string, filters []string) string {
+	return filepath.Join(cacheDir(), cachePrefix(kind, profile, filters)+region+".json")
+}
+
+// cachePrefix returns the start of the cache file names for a listing of kind
+// in one account, whatever the region
+func cachePrefix(kind, profile string, filters []string) string {
+	key := strings.Join(append([]string{strconv.Itoa(cacheVersion), profile, strconv.Itoa(maxResults)}, filters...), "\x00")
+	sum := sha256.Sum256([]byte(key))
+	return kind + "-" + hex.EncodeToString(sum[:8]) + "-"
+}
+
+// cachedRegions returns the regions with a cached listing of kind in one
+// account, in order, so --offline --all-regions doesn't need DescribeRegions
+func cachedRegions(kind, profile string, filters []string) ([]string, error) {
+	entries, err := os.ReadDir(cacheDir())
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return nil, fmt.Errorf("failed to read the cache: %w", err)
+	}
+
+	prefix := cachePrefix(kind, profile, filters)
+	var regions []string
+	for _, entry := range entries {
+		name := entry.Name()
+		if strings.HasPrefix(name, prefix) && strings.HasSuffix(name, ".json") {
+			regions = append(regions, strings.TrimSuffix(strings.TrimPrefix(name, prefix), ".json"))
+		}
+	}
+	if len(regions) == 0 {
+		return nil, fmt.Errorf("nothing cached for %s in any region; run once without --offline", kind)
+	}
+	sort.Strings(regions)
+
+	return regions, nil
+}
+
+// cachedList returns the listing of kind for the account (whose Config
+// targets one region) from the cache when it is younger than --cache-ttl.
+// Otherwise, or with --refresh, list is called and its results are cached.
+// With --offline only the cache is used, however old. Cached listings are
+// only good enough for pickers and lists; see refreshInstances before acting
+// on an instance.
+func cachedList[T any](ctx context.Context, kind string, acct awsAccount, filters []string, list func(context.Context, aws.Config) ([]T, error)) ([]T, error) {
+	path := cachePath(kind, acct.Profile, acct.Config.Region, filters)
+
+	if !refreshCache && (offline || cacheTTL > 0) {
+		cached, err := readCache[T](path)
+		switch {
+		case err == nil && offline:
+			return cached.Items, nil
+		case err == nil && time.Since(cached.FetchedAt) < cacheTTL:
+			return cached.Items, nil
+		case offline:
+			return nil, fmt.Errorf("nothing cached for %s in %s; run once without --offline", kind, acct.Config.Region)
+		}
+	}
+
+	items, err := list(ctx, acct.Config)
+	if err != nil {
+		return nil, err
+	}
+
+	if cacheTTL > 0 {
+		if err := writeCache(path, items); err != nil {
+			fmt.Fprintf(os.Stderr, "Warning: could not write the inventory cache: %v\n", err)
+		}
+	}
+
+	return items, nil
+}
+
+func readCache[T any](path string) (cacheFile[T], error) {
+	var cached cacheFile[T]
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return cached, err
+	}
+	if err := json.Unmarshal(data, &cached); err != nil {
+		return cached, fmt.Errorf("corrupt cache file %s: %w", path, err)
+	}
+
+	return cached, nil
+}
+
+func writeCache[T any](path string, items []T) error {
+	data, err := json.Marshal(cacheFile[T]{FetchedAt: time.Now(), Items: items})
+	if err != nil {
+		return err
+	}
+	return writeFileAtomic(path, data)
+}
+
+func handleCacheClear() {
+	err := os.RemoveAll(cacheDir())
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		log.Fatalf("Failed to clear the cache: %v", err)
+	}
+	fmt.Println("Cache cleared")
+}
@@ -0,0 +1,128 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+)
+
+func TestCachePath(t *testing.T) {
+	base := cachePath(cacheKindEC2, "prod", "us-east-1", nil)
+
+	tests := []struct {
+		name string
+		path string
+	}{
+		{"kind", cachePath(cacheKindRDS, "prod", "us-east-1", nil)},
+		{"profile", cachePath(cacheKindEC2, "dev", "us-east-1", nil)},
+		{"region", cachePath(cacheKindEC2, "prod", "eu-west-1", nil)},
+		{"filters", cachePath(cacheKindEC2, "prod", "us-east-1", []string{"tag:Env=prod"})},
+	}
+
+	for _, tt := range tests {
+		if tt.path == base {
+			t.Errorf("Expected a different %s to give a different cache path, got %s", tt.name, tt.path)
+		}
+	}
+
+	if again := cachePath(cacheKindEC2, "prod", "us-east-1", nil); again != base {
+		t.Errorf("Expected the same key to give the same path, got %s and %s", base, again)
+	}
+}
+
+func TestCacheRoundTrip(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+
+	path := cachePath(cacheKindEC2, "", "us-east-1", nil)
+	if err := writeCache(path, []Instance{{ID: "i-1", Name: "web"}}); err != nil {
+		t.Fatalf("Expected the cache to be written, got %v", err)
+	}
+
+	cached, err := readCache[Instance](path)
+	if err != nil {
+		t.Fatalf("Expected the cache to be read, got %v", err)
+	}
+	if len(cached.Items) != 1 || cached.Items[0].ID != "i-1" || cached.Items[0].Name != "web" {
+		t.Errorf("Expected the cached instance back, got %+v", cached.Items)
+	}
+	if time.Since(cached.FetchedAt) > time.Minute {
+		t.Errorf("Expected a recent fetch time, got %v", cached.FetchedAt)
+	}
+}
+
+func TestCachedList(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	defer func() { cacheTTL, refreshCache, offline = 0, false, false }()
+
+	ctx := context.Background()
+	acct := awsAccount{Config: aws.Config{Region: "us-east-1"}}
+
+	calls := 0
+	list := func(ctx context.Context, cfg aws.Config) ([]Instance, error) {
+		calls++
+		return []Instance{{ID: "i-live"}}, nil
+	}
+	failing := func(ctx context.Context, cfg aws.Config) ([]Instance, error) {
+		return nil, errors.New("no credentials")
+	}
+
+	// Offline with nothing cached fails
+	offline = true
+	if _, err := cachedList(ctx, cacheKindEC2, acct, nil, list); err == nil {
+		t.Errorf("Expected an error offline with an empty cache")
+	}
+	if calls != 0 {
+		t.Errorf("Expected no listing offline, got %d calls", calls)
+	}
+
+	// A stale cache is still used offline
+	path := cachePath(cacheKindEC2, "", "us-east-1", nil)
+	if err := writeCache(path, []Instance{{ID: "i-cached"}}); err != nil {
+		t.Fatalf("Expected the cache to be written, got %v", err)
+	}
+	items, err := cachedList(ctx, cacheKindEC2, acct, nil, failing)
+	if err != nil || len(items) != 1 || items[0].ID != "i-cached" {
+		t.Errorf("Expected the cached instance offline, got %v, %v", items, err)
+	}
+
+	// --refresh lists and updates the cache
+	offline, refreshCache, cacheTTL = false, true, time.Hour
+	items, err = cachedList(ctx, cacheKindEC2, acct, nil, list)
+	if err != nil || len(items) != 1 || items[0].ID != "i-live" || calls != 1 {
+		t.Errorf("Expected a live listing with --refresh, got %v, %v after %d calls", items, err, calls)
+	}
+	cached, err := readCache[Instance](path)
+	if err != nil || len(cached.Items) != 1 || cached.Items[0].ID != "i-live" {
+		t.Errorf("Expected --refresh to update the cache, got %+v, %v", cached.Items, err)
+	}
+
+	// A fresh cache is used without waiting for AWS
+	refreshCache = false
+	items, err = cachedList(ctx, cacheKindEC2, acct, nil, failing)
+	if err != nil || len(items) != 1 || items[0].ID != "i-live" {
+		t.Errorf("Expected the fresh cached instance, got %v, %v", items, err)
+	}
+
+	// A cache older than the TTL is listed again and updated
+	newer := func(ctx context.Context, cfg aws.Config) ([]Instance, error) {
+		return []Instance{{ID: "i-newer"}}, nil
+	}
+	cacheTTL = time.Nanosecond
+	items, err = cachedList(ctx, cacheKindEC2, acct, nil, newer)
+	if err != nil || len(items) != 1 || items[0].ID != "i-newer" {
+		t.Errorf("Expected a live listing once the cache expired, got %v, %v", items, err)
+	}
+	cached, err = readCache[Instance](path)
+	if err != nil || len(cached.Items) != 1 || cached.Items[0].ID != "i-newer" {
+		t.Errorf("Expected the expired cache to be updated, got %+v, %v", cached.Items, err)
+	}
+
+	// With the cache disabled every listing goes to AWS
+	cacheTTL = 0
+	if _, err := cachedList(ctx, cacheKindEC2, acct, nil, failing); err == nil {
+		t.Errorf("Expected the listing error with the cache disabled")
+	}
+}
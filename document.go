@@ -0,0 +1,177 @@
+package main
+
+import (
+	"fmt"
+	"slices"
+	"sort"
+	"strings"
+	"text/template"
+	"text/template/parse"
+)
+
+// defaultSessionDocument starts the configured shell as an interactive command
+const defaultSessionDocument = "AWS-StartInteractiveCommand"
+
+// sessionDocument is the SSM document a shell session starts, with parameter
+// values that are Go templates over sessionTemplateData
+type sessionDocument struct {
+	Name       string
+	Parameters map[string]string
+}
+
+// sessionTemplateData is what document parameter templates can refer to,
+// e.g. {{.Instance.Name}}, {{.Instance.Tags.Env}}, {{.Shell}} or {{.Command}}
+type sessionTemplateData struct {
+	Instance         Instance
+	Shell            string
+	RunAs            string
+	WorkingDirectory string
+}
+
+// Command is the command line that starts the shell as RunAs in
+// WorkingDirectory
+func (d sessionTemplateData) Command() (string, error) {
+	return shellCommand(d.Instance.Platform, d.Shell, d.RunAs, d.WorkingDirectory)
+}
+
+// shellDocument returns the document to start for a shell configuration,
+// applying the --document and --param overrides. Configured parameters only
+// apply to the configured document. The default document runs the shell
+// command unless it is given explicitly.
+func shellDocument(shell ShellConfig, document string, params []string) (sessionDocument, error) {
+	doc := sessionDocument{Name: shell.Document, Parameters: map[string]string{}}
+	if doc.Name == "" {
+		doc.Name = defaultSessionDocument
+	}
+
+	if document == "" || document == doc.Name {
+		for k, v := range shell.Parameters {
+			doc.Parameters[k] = v
+		}
+	} else {
+		doc.Name = document
+	}
+
+	overrides, err := parseDocumentParams(params)
+	if err != nil {
+		return sessionDocument{}, err
+	}
+	for k, v := range overrides {
+		doc.Parameters[k] = v
+	}
+
+	if _, ok := doc.Parameters["command"]; !ok && doc.Name == defaultSessionDocument {
+		doc.Parameters["command"] = "{{.Command}}"
+	}
+	return doc, nil
+}
+
+// parseDocumentParams parses --param values of the form key=value
+func parseDocumentParams(params []string) (map[string]string, error) {
+	parsed := make(map[string]string, len(params))
+	for _, p := range params {
+		key, value, ok := strings.Cut(p, "=")
+		if !ok || strings.TrimSpace(key) == "" {
+			return nil, fmt.Errorf("invalid parameter %q (want key=value)", p)
+		}
+		parsed[strings.TrimSpace(key)] = value
+	}
+	return parsed, nil
+}
+
+// uses reports whether any parameter refers to one of the template fields,
+// e.g. uses("Shell", "Command"). The templates are parsed, so text, comments
+// and fields of other values such as {{.Instance.Tags.Shell}} don't count.
+func (d sessionDocument) uses(fields ...string) bool {
+	for _, v := range d.Parameters {
+		tmpl, err := template.New("").Parse(v)
+		if err != nil {
+			continue
+		}
+		for _, t := range tmpl.Templates() {
+			if t.Tree != nil && nodeUses(t.Tree.Root, fields) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// nodeUses reports whether a template parse tree refers to one of the fields
+// of the template data, as {{.Field}} or {{$.Field}}
+func nodeUses(node parse.Node, fields []string) bool {
+	switch n := node.(type) {
+	case *parse.FieldNode:
+		return slices.Contains(fields, n.Ident[0])
+	case *parse.VariableNode:
+		return len(n.Ident) > 1 && n.Ident[0] == "$" && slices.Contains(fields, n.Ident[1])
+	case *parse.ChainNode:
+		return nodeUses(n.Node, fields)
+	case *parse.ListNode:
+		if n == nil {
+			return false
+		}
+		for _, child := range n.Nodes {
+			if nodeUses(child, fields) {
+				return true
+			}
+		}
+	case *parse.ActionNode:
+		return nodeUses(n.Pipe, fields)
+	case *parse.TemplateNode:
+		return n.Pipe != nil && nodeUses(n.Pipe, fields)
+	case *parse.IfNode:
+		return nodeUses(n.Pipe, fields) || nodeUses(n.List, fields) || nodeUses(n.ElseList, fields)
+	case *parse.RangeNode:
+		return nodeUses(n.Pipe, fields) || nodeUses(n.List, fields) || nodeUses(n.ElseList, fields)
+	case *parse.WithNode:
+		return nodeUses(n.Pipe, fields) || nodeUses(n.List, fields) || nodeUses(n.ElseList, fields)
+	case *parse.PipeNode:
+		if n == nil {
+			return false
+		}
+		for _, cmd := range n.Cmds {
+			if nodeUses(cmd, fields) {
+				return true
+			}
+		}
+	case *parse.CommandNode:
+		for _, arg := range n.Args {
+			if nodeUses(arg, fields) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// render executes the parameter templates for an instance and its shell
+// settings, returning StartSession parameters
+func (d sessionDocument) render(instance Instance, shell ShellConfig) (map[string][]string, error) {
+	data := sessionTemplateData{
+		Instance:         instance,
+		Shell:            shell.Shell,
+		RunAs:            shell.RunAs,
+		WorkingDirectory: shell.WorkingDirectory,
+	}
+
+	keys := make([]string, 0, len(d.Parameters))
+	for k := range d.Parameters {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	params := make(map[string][]string, len(keys))
+	for _, k := range keys {
+		tmpl, err := template.New(k).Option("missingkey=zero").Parse(d.Parameters[k])
+		if err != nil {
+			return nil, fmt.Errorf("invalid template in parameter %s: %w", k, err)
+		}
+		var b strings.Builder
+		if err := tmpl.Execute(&b, data); err != nil {
+			return nil, fmt.Errorf("failed to render parameter %s: %w", k, err)
+		}
+		params[k] = []string{b.String()}
+	}
+	return params, nil
+}
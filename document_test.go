@@ -0,0 +1,158 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestShellDocument(t *testing.T) {
+	custom := ShellConfig{
+		Shell:      "/bin/bash",
+		Document:   "Company-RestrictedShell",
+		Parameters: map[string]string{"ticket": "{{.Instance.Tags.Ticket}}"},
+	}
+
+	tests := []struct {
+		name     string
+		shell    ShellConfig
+		document string
+		params   []string
+		want     sessionDocument
+		wantErr  bool
+	}{
+		{
+			name:  "default document runs the shell",
+			shell: ShellConfig{Shell: "/bin/bash"},
+			want:  sessionDocument{Name: defaultSessionDocument, Parameters: map[string]string{"command": "{{.Command}}"}},
+		},
+		{
+			name:  "configured document and parameters",
+			shell: custom,
+			want:  sessionDocument{Name: "Company-RestrictedShell", Parameters: map[string]string{"ticket": "{{.Instance.Tags.Ticket}}"}},
+		},
+		{
+			name:   "param overrides a configured parameter",
+			shell:  custom,
+			params: []string{"ticket=INC-42", "reason=outage"},
+			want:   sessionDocument{Name: "Company-RestrictedShell", Parameters: map[string]string{"ticket": "INC-42", "reason": "outage"}},
+		},
+		{
+			name:     "another document drops the configured parameters",
+			shell:    custom,
+			document: "AWS-StartNonInteractiveCommand",
+			params:   []string{"command=uptime"},
+			want:     sessionDocument{Name: "AWS-StartNonInteractiveCommand", Parameters: map[string]string{"command": "uptime"}},
+		},
+		{
+			name:     "naming the configured document keeps its parameters",
+			shell:    custom,
+			document: "Company-RestrictedShell",
+			want:     sessionDocument{Name: "Company-RestrictedShell", Parameters: map[string]string{"ticket": "{{.Instance.Tags.Ticket}}"}},
+		},
+		{
+			name:   "explicit command for the default document",
+			shell:  ShellConfig{Shell: "/bin/bash"},
+			params: []string{"command=sudo -iu app bash"},
+			want:   sessionDocument{Name: defaultSessionDocument, Parameters: map[string]string{"command": "sudo -iu app bash"}},
+		},
+		{
+			name:    "invalid param",
+			shell:   ShellConfig{Shell: "/bin/bash"},
+			params:  []string{"command"},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		got, err := shellDocument(tt.shell, tt.document, tt.params)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("%s: expected error %v, got %v", tt.name, tt.wantErr, err)
+			continue
+		}
+		if !tt.wantErr && !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: expected %+v, got %+v", tt.name, tt.want, got)
+		}
+	}
+}
+
+func TestParseDocumentParams(t *testing.T) {
+	got, err := parseDocumentParams([]string{"command=echo a=b", " user =alice", "empty="})
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	want := map[string]string{"command": "echo a=b", "user": "alice", "empty": ""}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Expected %v, got %v", want, got)
+	}
+
+	for _, bad := range []string{"command", "=value", " =value"} {
+		if _, err := parseDocumentParams([]string{bad}); err == nil {
+			t.Errorf("Expected an error for %q", bad)
+		}
+	}
+}
+
+func TestSessionDocumentRender(t *testing.T) {
+	instance := Instance{ID: "i-0123456789abcdef0", Name: "web-1", Platform: "linux", Tags: map[string]string{"Env": "prod"}}
+
+	doc := sessionDocument{
+		Name: "Company-RestrictedShell",
+		Parameters: map[string]string{
+			"command": "{{.Command}}",
+			"shell":   "{{.Shell}} -l",
+			"user":    "{{.RunAs}}",
+			"label":   "{{.Instance.Name}} ({{.Instance.ID}}) in {{.Instance.Tags.Env}}",
+			"ticket":  "{{.Instance.Tags.Ticket}}",
+		},
+	}
+
+	got, err := doc.render(instance, ShellConfig{Shell: "/bin/zsh", RunAs: "app"})
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	want := map[string][]string{
+		"command": {"sudo -iu app /bin/zsh"},
+		"shell":   {"/bin/zsh -l"},
+		"user":    {"app"},
+		"label":   {"web-1 (i-0123456789abcdef0) in prod"},
+		"ticket":  {""},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Expected %v, got %v", want, got)
+	}
+
+	for _, bad := range []string{"{{.Instance.Nmae}}", "{{.Shell"} {
+		doc := sessionDocument{Name: "X", Parameters: map[string]string{"p": bad}}
+		if _, err := doc.render(instance, ShellConfig{Shell: "/bin/bash"}); err == nil {
+			t.Errorf("Expected an error for %q", bad)
+		}
+	}
+}
+
+func TestSessionDocumentUses(t *testing.T) {
+	tests := []struct {
+		params map[string]string
+		fields []string
+		want   bool
+	}{
+		{map[string]string{"command": "{{.Command}}"}, []string{"Shell", "Command"}, true},
+		{map[string]string{"shell": "{{ .Shell }} -l"}, []string{"Shell", "Command"}, true},
+		{map[string]string{"runAsUser": "{{.RunAs}}"}, []string{"Shell", "Command"}, false},
+		{map[string]string{"runAsUser": "{{.RunAs}}"}, []string{"RunAs", "Command"}, true},
+		{map[string]string{"ticket": "{{.Instance.Tags.Ticket}}"}, []string{"Shell"}, false},
+		{map[string]string{"command": "{{$.Command}}"}, []string{"Shell", "Command"}, true},
+		{map[string]string{"command": "{{if .Instance.Name}}{{.Command | printf \"%s\"}}{{end}}"}, []string{"Command"}, true},
+		{map[string]string{"command": "{{with $s := .Shell}}{{$s}}{{end}}"}, []string{"Shell"}, true},
+		{map[string]string{"tag": "{{.Instance.Tags.Shell}}"}, []string{"Shell"}, false},
+		{map[string]string{"note": "see docs.Shell {{/* .Command */}}"}, []string{"Shell", "Command"}, false},
+		{map[string]string{"bad": "{{.Shell"}, []string{"Shell"}, false},
+		{nil, []string{"Shell"}, false},
+	}
+
+	for _, tt := range tests {
+		doc := sessionDocument{Name: "X", Parameters: tt.params}
+		if got := doc.uses(tt.fields...); got != tt.want {
+			t.Errorf("uses(%v) for %v: expected %v, got %v", tt.fields, tt.params, tt.want, got)
+		}
+	}
+}
@@ -0,0 +1,387 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/AlecAivazis/survey/v2"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ssm"
+	ssmtypes "github.com/aws/aws-sdk-go-v2/service/ssm/types"
+)
+
+// sendCommandBatchSize is the maximum number of instance IDs per SendCommand call
+const sendCommandBatchSize = 50
+
+// commandPollInterval is how often GetCommandInvocation is polled per target
+const commandPollInterval = 2 * time.Second
+
+// cancelCommandTimeout bounds the CancelCommand call made after a timeout
+const cancelCommandTimeout = 10 * time.Second
+
+// commandResult is the outcome of a Run Command invocation on one instance
+type commandResult struct {
+	Instance Instance
+	Status   string
+	ExitCode int32
+	Stdout   string
+	Stderr   string
+	Err      error
+}
+
+func (r commandResult) succeeded() bool {
+	return r.Err == nil && r.Status == string(ssmtypes.CommandInvocationStatusSuccess)
+}
+
+func handleEC2Exec(ctx context.Context, cfg aws.Config, args []string) {
+	if len(ec2FilterExprs) == 0 && len(execTargets) == 0 {
+		log.Fatalf("Refusing to run on every instance: select targets with --filter or --target")
+	}
+
+	// Targets are matched against a live listing, as with --refresh, so a
+	// stale cache can't add or leave out hosts
+	refreshCache = true
+
+	instances, err := listInstances(ctx, cfg)
+	if err != nil {
+		log.Fatalf("Failed to list instances: %v", err)
+	}
+
+	if len(execTargets) > 0 {
+		instances, err = matchTargets(instances, execTargets)
+		if err != nil {
+			log.Fatalf("Failed to resolve targets: %v", err)
+		}
+	}
+
+	// The listing may be cached, so check each host's current state
+	instances, gone, err := refreshInstances(ctx, cfg, instances)
+	if err != nil {
+		log.Fatalf("Failed to describe instances: %v", err)
+	}
+
+	var runnable []Instance
+	var skipped []commandResult
+	for _, inst := range gone {
+		skipped = append(skipped, commandResult{Instance: inst, Status: "Skipped", Err: fmt.Errorf("instance %s no longer exists", inst.ID)})
+	}
+	for _, inst := range instances {
+		if err := checkSessionTarget(inst); err != nil {
+			skipped = append(skipped, commandResult{Instance: inst, Status: "Skipped", Err: err})
+			continue
+		}
+		runnable = append(runnable, inst)
+	}
+
+	if len(runnable) == 0 {
+		log.Fatalf("No running, SSM-reachable instances match (%d skipped)", len(skipped))
+	}
+
+	if isInteractive() && !assumeYes {
+		confirmed := false
+		prompt := &survey.Confirm{
+			Message: fmt.Sprintf("Run %q on %d instance(s)?", execCommandLine("linux", args), len(runnable)),
+		}
+		if err := survey.AskOne(prompt, &confirmed); err != nil || !confirmed {
+			fmt.Println("Aborted.")
+			return
+		}
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, execTimeout)
+	defer cancel()
+
+	results := runCommand(ctx, cfg, runnable, args, execConcurrency)
+	results = append(results, skipped...)
+
+	printCommandSummary(os.Stdout, results)
+
+	if code := commandExitCode(results); code != 0 {
+		os.Exit(code)
+	}
+}
+
+// matchTargets returns the instances matching any of the targets, without duplicates
+func matchTargets(instances []Instance, targets []string) ([]Instance, error) {
+	seen := make(map[string]bool)
+	var matched []Instance
+
+	for _, target := range targets {
+		matches, err := matchInstances(instances, target)
+		if err != nil {
+			return nil, err
+		}
+		if len(matches) == 0 {
+			return nil, fmt.Errorf("no instance matches %q", target)
+		}
+		for _, inst := range matches {
+			if !seen[inst.ID] {
+				seen[inst.ID] = true
+				matched = append(matched, inst)
+			}
+		}
+	}
+
+	return matched, nil
+}
+
+// commandGroup is a set of instances that can share one SendCommand call
+type commandGroup struct {
+	Profile  string
+	Region   string
+	Document string
+}
+
+// runCommand sends the command in args to the instances with SSM Run Command
+// and waits for every invocation, polling at most concurrency targets at a
+// time. Each host's output is printed as soon as it finishes.
+func runCommand(ctx context.Context, cfg aws.Config, instances []Instance, args []string, concurrency int) []commandResult {
+	if concurrency < 1 {
+		concurrency = 1
+	}
+
+	// Commands are sent per account and region, and Windows and Linux
+	// instances need different documents
+	groups := make(map[commandGroup][]Instance)
+	for _, inst := range instances {
+		group := commandGroup{Profile: inst.Profile, Region: inst.Region, Document: runCommandDocument(inst.Platform)}
+		groups[group] = append(groups[group], inst)
+	}
+
+	var (
+		mu      sync.Mutex
+		wg      sync.WaitGroup
+		results []commandResult
+	)
+	sem := make(chan struct{}, concurrency)
+
+	record := func(result commandResult) {
+		mu.Lock()
+		defer mu.Unlock()
+		printCommandOutput(result)
+		results = append(results, result)
+	}
+
+	for group, targets := range groups {
+		ssmClient := ssm.NewFromConfig(accountConfig(cfg, group.Profile, group.Region))
+		command := execCommandLine(targets[0].Platform, args)
+
+		for start := 0; start < len(targets); start += sendCommandBatchSize {
+			batch := targets[start:min(start+sendCommandBatchSize, len(targets))]
+
+			var ids []string
+			for _, inst := range batch {
+				ids = append(ids, inst.ID)
+			}
+
+			output, err := ssmClient.SendCommand(ctx, &ssm.SendCommandInput{
+				DocumentName:   aws.String(group.Document),
+				InstanceIds:    ids,
+				Parameters:     map[string][]string{"commands": {command}},
+				Comment:        aws.String("aws-go-tools ec2 exec"),
+				MaxConcurrency: aws.String(strconv.Itoa(concurrency)),
+				MaxErrors:      aws.String("100%"),
+			})
+			if err != nil {
+				for _, inst := range batch {
+					record(commandResult{Instance: inst, Status: "Failed", Err: fmt.Errorf("failed to send command: %w", err)})
+				}
+				continue
+			}
+
+			commandID := aws.ToString(output.Command.CommandId)
+			for _, inst := range batch {
+				wg.Add(1)
+				go func(inst Instance) {
+					defer wg.Done()
+					sem <- struct{}{}
+					defer func() { <-sem }()
+					record(waitForInvocation(ctx, ssmClient, commandID, inst))
+				}(inst)
+			}
+		}
+	}
+
+	wg.Wait()
+	return results
+}
+
+// waitForInvocation polls GetCommandInvocation until the command has finished on the instance
+func waitForInvocation(ctx context.Context, ssmClient *ssm.Client, commandID string, inst Instance) commandResult {
+	result := commandResult{Instance: inst}
+
+	for {
+		output, err := ssmClient.GetCommandInvocation(ctx, &ssm.GetCommandInvocationInput{
+			CommandId:  aws.String(commandID),
+			InstanceId: aws.String(inst.ID),
+		})
+
+		// The invocation may not be visible immediately after SendCommand
+		var notYet *ssmtypes.InvocationDoesNotExist
+		switch {
+		case err != nil && ctx.Err() != nil:
+			// The deadline passed during the request; handled below
+		case errors.As(err, &notYet):
+		case err != nil:
+			result.Status = "Failed"
+			result.Err = fmt.Errorf("failed to get command invocation: %w", err)
+			return result
+		default:
+			switch output.Status {
+			case ssmtypes.CommandInvocationStatusPending,
+				ssmtypes.CommandInvocationStatusInProgress,
+				ssmtypes.CommandInvocationStatusDelayed,
+				ssmtypes.CommandInvocationStatusCancelling:
+			default:
+				result.Status = string(output.Status)
+				result.ExitCode = output.ResponseCode
+				result.Stdout = aws.ToString(output.StandardOutputContent)
+				result.Stderr = aws.ToString(output.StandardErrorContent)
+				return result
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			result.Status = "TimedOut"
+			result.Err = fmt.Errorf("gave up waiting for the command: %w", ctx.Err())
+			if err := cancelInvocation(ctx, ssmClient, commandID, inst.ID); err != nil {
+				result.Err = fmt.Errorf("%w (and failed to cancel it: %v)", result.Err, err)
+			}
+			return result
+		case <-time.After(commandPollInterval):
+		}
+	}
+}
+
+// cancelInvocation cancels the command on the instance after ctx has expired,
+// so it doesn't keep running unobserved
+func cancelInvocation(ctx context.Context, ssmClient *ssm.Client, commandID, instanceID string) error {
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), cancelCommandTimeout)
+	defer cancel()
+
+	_, err := ssmClient.CancelCommand(ctx, &ssm.CancelCommandInput{
+		CommandId:   aws.String(commandID),
+		InstanceIds: []string{instanceID},
+	})
+	return err
+}
+
+// powerShellSafe matches arguments that need no quoting in PowerShell
+var powerShellSafe = regexp.MustCompile(`^[A-Za-z0-9_.:/\\-]+$`)
+
+// execCommandLine turns the arguments given after "--" into a command line
+// for the platform's Run Command document. A single argument is used as
+// written, so pipes and redirections can be passed as one quoted string.
+// Several arguments are quoted so each reaches the command unchanged.
+func execCommandLine(platform string, args []string) string {
+	if len(args) == 1 {
+		return args[0]
+	}
+
+	quoted := make([]string, len(args))
+	if platform != "windows" {
+		for i, arg := range args {
+			quoted[i] = shellQuote(arg)
+		}
+		return strings.Join(quoted, " ")
+	}
+
+	for i, arg := range args {
+		quoted[i] = arg
+		if !powerShellSafe.MatchString(arg) {
+			quoted[i] = "'" + strings.ReplaceAll(arg, "'", "''") + "'"
+		}
+	}
+	// A quoted command name is only run with the call operator
+	if !powerShellSafe.MatchString(args[0]) {
+		return "& " + strings.Join(quoted, " ")
+	}
+	return strings.Join(quoted, " ")
+}
+
+// runCommandDocument picks the Run Command document for an instance platform
+func runCommandDocument(platform string) string {
+	if platform == "windows" {
+		return "AWS-RunPowerShellScript"
+	}
+	return "AWS-RunShellScript"
+}
+
+// displayName is the instance's Name tag, or its ID when it has none
+func displayName(inst Instance) string {
+	if inst.Name != "" {
+		return inst.Name
+	}
+	return inst.ID
+}
+
+func printCommandOutput(result commandResult) {
+	name := displayName(result.Instance)
+	fmt.Fprint(os.Stdout, prefixLines(name, result.Stdout))
+	fmt.Fprint(os.Stderr, prefixLines(name, result.Stderr))
+	if result.Err != nil {
+		fmt.Fprintf(os.Stderr, "[%s] %v\n", name, result.Err)
+	}
+}
+
+// prefixLines prefixes every line of output with the host name
+func prefixLines(name, output string) string {
+	output = strings.TrimRight(output, "\r\n")
+	if output == "" {
+		return ""
+	}
+
+	var b strings.Builder
+	for _, line := range strings.Split(output, "\n") {
+		fmt.Fprintf(&b, "[%s] %s\n", name, strings.TrimRight(line, "\r"))
+	}
+	return b.String()
+}
+
+func printCommandSummary(w io.Writer, results []commandResult) {
+	succeeded, failed, skipped := 0, 0, 0
+	for _, r := range results {
+		switch {
+		case r.succeeded():
+			succeeded++
+		case r.Status == "Skipped":
+			skipped++
+		default:
+			failed++
+		}
+	}
+
+	fmt.Fprintln(w)
+	fmt.Fprintf(w, "Summary: %d succeeded, %d failed, %d skipped\n", succeeded, failed, skipped)
+	for _, r := range results {
+		if r.succeeded() {
+			continue
+		}
+		detail := fmt.Sprintf("exit code %d", r.ExitCode)
+		if r.Err != nil {
+			detail = r.Err.Error()
+		}
+		fmt.Fprintf(w, "  %-9s %s (%s): %s\n", strings.ToUpper(r.Status), displayName(r.Instance), r.Instance.ID, detail)
+	}
+}
+
+// commandExitCode is 0 when every host succeeded and 1 when any host failed
+// or was skipped
+func commandExitCode(results []commandResult) int {
+	for _, r := range results {
+		if !r.succeeded() {
+			return 1
+		}
+	}
+	return 0
+}
@@ -0,0 +1,194 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/service/ssm"
+)
+
+func TestRunCommandDocument(t *testing.T) {
+	if got := runCommandDocument("windows"); got != "AWS-RunPowerShellScript" {
+		t.Errorf("Expected AWS-RunPowerShellScript for windows, got %s", got)
+	}
+	if got := runCommandDocument("linux"); got != "AWS-RunShellScript" {
+		t.Errorf("Expected AWS-RunShellScript for linux, got %s", got)
+	}
+}
+
+func TestPrefixLines(t *testing.T) {
+	tests := []struct {
+		name   string
+		output string
+		want   string
+	}{
+		{"Empty output", "", ""},
+		{"Single line", "up 3 days\n", "[web-1] up 3 days\n"},
+		{"Multiple lines", "a\nb\n", "[web-1] a\n[web-1] b\n"},
+		{"Windows line endings", "a\r\nb\r\n", "[web-1] a\n[web-1] b\n"},
+		{"No trailing newline", "a", "[web-1] a\n"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := prefixLines("web-1", tt.output); got != tt.want {
+				t.Errorf("Expected %q, got %q", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestMatchTargets(t *testing.T) {
+	instances := []Instance{
+		{ID: "i-1", Name: "web-1"},
+		{ID: "i-2", Name: "web-2"},
+		{ID: "i-3", Name: "db-1"},
+	}
+
+	matched, err := matchTargets(instances, []string{"web-*", "web-1", "i-3"})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if len(matched) != 3 {
+		t.Errorf("Expected 3 unique instances, got %d", len(matched))
+	}
+
+	if _, err := matchTargets(instances, []string{"web-*", "cache-*"}); err == nil {
+		t.Error("Expected an error when a target matches nothing")
+	}
+}
+
+func TestCommandSummaryAndExitCode(t *testing.T) {
+	ok := commandResult{Instance: Instance{ID: "i-1", Name: "web-1"}, Status: "Success"}
+	failed := commandResult{Instance: Instance{ID: "i-2", Name: "web-2"}, Status: "Failed", ExitCode: 2}
+	skipped := commandResult{Instance: Instance{ID: "i-3"}, Status: "Skipped", Err: errors.New("instance i-3 is not in running state")}
+
+	if code := commandExitCode([]commandResult{ok}); code != 0 {
+		t.Errorf("Expected exit code 0, got %d", code)
+	}
+	if code := commandExitCode([]commandResult{ok, failed}); code != 1 {
+		t.Errorf("Expected exit code 1 with a failed host, got %d", code)
+	}
+	if code := commandExitCode([]commandResult{ok, skipped}); code != 1 {
+		t.Errorf("Expected exit code 1 with a skipped host, got %d", code)
+	}
+
+	var buf bytes.Buffer
+	printCommandSummary(&buf, []commandResult{ok, failed, skipped})
+	summary := buf.String()
+
+	if !strings.Contains(summary, "1 succeeded, 1 failed, 1 skipped") {
+		t.Errorf("Unexpected summary counts: %s", summary)
+	}
+	if !strings.Contains(summary, "web-2 (i-2): exit code 2") {
+		t.Errorf("Expected the failed host in the summary: %s", summary)
+	}
+	if !strings.Contains(summary, "i-3 (i-3)") {
+		t.Errorf("Expected the skipped host in the summary: %s", summary)
+	}
+}
+
+func TestExecCommandLine(t *testing.T) {
+	tests := []struct {
+		platform string
+		args     []string
+		want     string
+	}{
+		{"linux", []string{"uptime"}, "uptime"},
+		{"linux", []string{"ps aux | grep nginx"}, "ps aux | grep nginx"},
+		{"linux", []string{"sudo", "systemctl", "restart", "nginx"}, "sudo systemctl restart nginx"},
+		{"linux", []string{"grep", "a b", "/var/log/syslog"}, "grep 'a b' /var/log/syslog"},
+		{"linux", []string{"echo", "it's", "$HOME"}, `echo 'it'\''s' '$HOME'`},
+		{"windows", []string{"Get-Service", "-Name", "WinRM"}, "Get-Service -Name WinRM"},
+		{"windows", []string{"Write-Output", "it's $env:PATH"}, "Write-Output 'it''s $env:PATH'"},
+		{"windows", []string{`C:\Program Files\app.exe`, "--check"}, `& 'C:\Program Files\app.exe' --check`},
+	}
+
+	for _, tt := range tests {
+		if got := execCommandLine(tt.platform, tt.args); got != tt.want {
+			t.Errorf("execCommandLine(%q, %q): expected %q, got %q", tt.platform, tt.args, tt.want, got)
+		}
+	}
+}
+
+func TestWaitForInvocation(t *testing.T) {
+	client := ssm.NewFromConfig(fakeAWS(t, map[string]http.HandlerFunc{
+		"GetCommandInvocation": func(w http.ResponseWriter, r *http.Request) {
+			fmt.Fprint(w, `{"Status": "Success", "ResponseCode": 0, "StandardOutputContent": "up 3 days\n"}`)
+		},
+	}))
+
+	result := waitForInvocation(context.Background(), client, "cmd-1", Instance{ID: "i-1"})
+	if !result.succeeded() || result.Stdout != "up 3 days\n" {
+		t.Errorf("Expected a successful result with output, got %+v", result)
+	}
+}
+
+func TestWaitForInvocationTimeout(t *testing.T) {
+	var (
+		mu       sync.Mutex
+		canceled []string
+	)
+	cancelCommand := func(w http.ResponseWriter, r *http.Request) {
+		var input struct {
+			CommandId   string
+			InstanceIds []string
+		}
+		json.NewDecoder(r.Body).Decode(&input)
+		mu.Lock()
+		canceled = append(canceled, input.CommandId+" "+strings.Join(input.InstanceIds, ","))
+		mu.Unlock()
+		fmt.Fprint(w, `{}`)
+	}
+
+	tests := []struct {
+		name string
+		get  http.HandlerFunc
+	}{
+		{
+			name: "still running",
+			get: func(w http.ResponseWriter, r *http.Request) {
+				fmt.Fprint(w, `{"Status": "InProgress"}`)
+			},
+		},
+		{
+			name: "deadline during the request",
+			get: func(w http.ResponseWriter, r *http.Request) {
+				select {
+				case <-r.Context().Done():
+				case <-time.After(500 * time.Millisecond):
+				}
+				fmt.Fprint(w, `{"Status": "InProgress"}`)
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		canceled = nil
+		client := ssm.NewFromConfig(fakeAWS(t, map[string]http.HandlerFunc{
+			"GetCommandInvocation": tt.get,
+			"CancelCommand":        cancelCommand,
+		}))
+
+		ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+		result := waitForInvocation(ctx, client, "cmd-1", Instance{ID: "i-1"})
+		cancel()
+
+		if result.Status != "TimedOut" || !errors.Is(result.Err, context.DeadlineExceeded) {
+			t.Errorf("%s: expected TimedOut with a deadline error, got %s: %v", tt.name, result.Status, result.Err)
+		}
+		mu.Lock()
+		if len(canceled) != 1 || canceled[0] != "cmd-1 i-1" {
+			t.Errorf("%s: expected the command to be canceled on i-1, got %q", tt.name, canceled)
+		}
+		mu.Unlock()
+	}
+}
@@ -0,0 +1,161 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+// instanceFilter is a single --filter expression, e.g. tag:Env=prod or
+// instance-type=t3.*. Multiple comma-separated values are OR'ed together.
+type instanceFilter struct {
+	Name   string
+	Values []string
+}
+
+// ec2FilterAliases maps shorthand filter names onto EC2 API filter names
+var ec2FilterAliases = map[string]string{
+	"id":    "instance-id",
+	"name":  "tag:Name",
+	"state": "instance-state-name",
+	"type":  "instance-type",
+}
+
+// rdsFilterAliases maps shorthand filter names onto RDS filter names
+var rdsFilterAliases = map[string]string{
+	"id":            "db-instance-id",
+	"identifier":    "db-instance-id",
+	"name":          "db-instance-id",
+	"state":         "status",
+	"type":          "instance-class",
+	"instance-type": "instance-class",
+}
+
+// rdsServerFilters are the filter names DescribeDBInstances accepts. The API
+// matches them exactly, so patterns are evaluated client-side instead.
+var rdsServerFilters = map[string]bool{
+	"db-instance-id":  true,
+	"db-cluster-id":   true,
+	"dbi-resource-id": true,
+	"engine":          true,
+	"domain":          true,
+}
+
+// rdsClientFilters are the filter names that can be evaluated against an
+// RDSInstance after listing, in addition to tag:<key>.
+var rdsClientFilters = map[string]bool{
+	"db-instance-id": true,
+	"engine":         true,
+	"status":         true,
+	"instance-class": true,
+	"vpc-id":         true,
+}
+
+// parseFilters parses name=value expressions, resolving shorthand names
+// through aliases. Tag keys keep their case since tags are case-sensitive.
+func parseFilters(exprs []string, aliases map[string]string) ([]instanceFilter, error) {
+	var filters []instanceFilter
+
+	for _, expr := range exprs {
+		name, value, ok := strings.Cut(expr, "=")
+		name = strings.TrimSpace(name)
+		if !ok || name == "" || value == "" {
+			return nil, fmt.Errorf("invalid filter %q, expected name=value (e.g. tag:Env=prod)", expr)
+		}
+
+		if alias, found := aliases[strings.ToLower(name)]; found {
+			name = alias
+		} else if !strings.HasPrefix(name, "tag:") {
+			name = strings.ToLower(name)
+		}
+
+		filters = append(filters, instanceFilter{
+			Name:   name,
+			Values: strings.Split(value, ","),
+		})
+	}
+
+	return filters, nil
+}
+
+// hasFilter reports whether filters already contains a filter called name
+func hasFilter(filters []instanceFilter, name string) bool {
+	for _, f := range filters {
+		if f.Name == name {
+			return true
+		}
+	}
+	return false
+}
+
+// splitRDSFilters separates the filters DescribeDBInstances can evaluate from
+// those that have to be applied to the results.
+func splitRDSFilters(filters []instanceFilter) (server, client []instanceFilter, err error) {
+	for _, f := range filters {
+		if rdsServerFilters[f.Name] && !containsPattern(f.Values) {
+			server = append(server, f)
+			continue
+		}
+		if !rdsClientFilters[f.Name] && !strings.HasPrefix(f.Name, "tag:") {
+			if rdsServerFilters[f.Name] {
+				return nil, nil, fmt.Errorf("RDS filter %q does not support wildcards", f.Name)
+			}
+			return nil, nil, fmt.Errorf("unsupported RDS filter %q (supported: tag:<key>, db-instance-id, engine, status, instance-class, vpc-id, db-cluster-id, dbi-resource-id, domain)", f.Name)
+		}
+		client = append(client, f)
+	}
+	return server, client, nil
+}
+
+// rdsFilterValue returns the value of the attribute a client-side filter
+// applies to, and false when inst has no such attribute or tag.
+func rdsFilterValue(inst RDSInstance, name string) (string, bool) {
+	if key, ok := strings.CutPrefix(name, "tag:"); ok {
+		value, found := inst.Tags[key]
+		return value, found
+	}
+
+	switch name {
+	case "db-instance-id":
+		return inst.Identifier, true
+	case "engine":
+		return inst.Engine, true
+	case "status":
+		return inst.Status, true
+	case "instance-class":
+		return inst.InstanceClass, true
+	case "vpc-id":
+		return inst.VpcID, true
+	}
+	return "", false
+}
+
+// matchRDSFilters reports whether inst satisfies every client-side filter
+func matchRDSFilters(inst RDSInstance, filters []instanceFilter) bool {
+	for _, f := range filters {
+		value, ok := rdsFilterValue(inst, f.Name)
+		if !ok || !matchesAnyPattern(value, f.Values) {
+			return false
+		}
+	}
+	return true
+}
+
+// matchesAnyPattern reports whether value matches one of the glob patterns.
+// As in EC2's server-side filters, * also matches "/".
+func matchesAnyPattern(value string, patterns []string) bool {
+	for _, pattern := range patterns {
+		if globMatch(pattern, value) {
+			return true
+		}
+	}
+	return false
+}
+
+func containsPattern(values []string) bool {
+	for _, v := range values {
+		if isGlobPattern(v) {
+			return true
+		}
+	}
+	return false
+}
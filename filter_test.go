@@ -0,0 +1,110 @@
+package main
+
+import (
+	"testing"
+)
+
+func TestParseFilters(t *testing.T) {
+	tests := []struct {
+		name       string
+		expr       string
+		aliases    map[string]string
+		wantName   string
+		wantValues []string
+		wantErr    bool
+	}{
+		{"Tag filter", "tag:Env=prod", ec2FilterAliases, "tag:Env", []string{"prod"}, false},
+		{"Tag key keeps case", "tag:CostCenter=42", ec2FilterAliases, "tag:CostCenter", []string{"42"}, false},
+		{"Multiple values", "instance-type=t3.*,m5.large", ec2FilterAliases, "instance-type", []string{"t3.*", "m5.large"}, false},
+		{"EC2 state alias", "state=running", ec2FilterAliases, "instance-state-name", []string{"running"}, false},
+		{"EC2 name alias", "Name=web-*", ec2FilterAliases, "tag:Name", []string{"web-*"}, false},
+		{"RDS state alias", "state=available", rdsFilterAliases, "status", []string{"available"}, false},
+		{"Value containing equals", "tag:Query=a=b", ec2FilterAliases, "tag:Query", []string{"a=b"}, false},
+		{"Missing value", "vpc-id=", ec2FilterAliases, "", nil, true},
+		{"Missing separator", "vpc-id", ec2FilterAliases, "", nil, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			filters, err := parseFilters([]string{tt.expr}, tt.aliases)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Expected error=%v, got %v", tt.wantErr, err)
+			}
+			if tt.wantErr {
+				return
+			}
+			if filters[0].Name != tt.wantName {
+				t.Errorf("Expected name %s, got %s", tt.wantName, filters[0].Name)
+			}
+			if len(filters[0].Values) != len(tt.wantValues) {
+				t.Fatalf("Expected values %v, got %v", tt.wantValues, filters[0].Values)
+			}
+			for i, v := range tt.wantValues {
+				if filters[0].Values[i] != v {
+					t.Errorf("Expected value %s, got %s", v, filters[0].Values[i])
+				}
+			}
+		})
+	}
+}
+
+func TestSplitRDSFilters(t *testing.T) {
+	filters, err := parseFilters([]string{"engine=postgres", "engine=aurora-*", "tag:Env=prod", "state=available"}, rdsFilterAliases)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	server, client, err := splitRDSFilters(filters)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if len(server) != 1 || server[0].Values[0] != "postgres" {
+		t.Errorf("Expected only the exact engine filter server-side, got %v", server)
+	}
+	if len(client) != 3 {
+		t.Errorf("Expected 3 client-side filters, got %d", len(client))
+	}
+
+	unsupported, _ := parseFilters([]string{"instance-state-name=running"}, rdsFilterAliases)
+	if _, _, err := splitRDSFilters(unsupported); err == nil {
+		t.Error("Expected an error for an unsupported RDS filter")
+	}
+}
+
+func TestMatchRDSFilters(t *testing.T) {
+	inst := RDSInstance{
+		Identifier:    "orders-db",
+		Engine:        "aurora-postgresql",
+		Status:        "available",
+		InstanceClass: "db.r6g.large",
+		VpcID:         "vpc-123",
+		Tags:          map[string]string{"Env": "prod", "Team": "platform/data"},
+	}
+
+	tests := []struct {
+		name  string
+		exprs []string
+		want  bool
+	}{
+		{"No filters", nil, true},
+		{"Tag match", []string{"tag:Env=prod"}, true},
+		{"Tag mismatch", []string{"tag:Env=dev"}, false},
+		{"Missing tag", []string{"tag:Owner=*"}, false},
+		{"Tag pattern across a slash", []string{"tag:Team=plat*"}, true},
+		{"Engine pattern", []string{"engine=aurora-*"}, true},
+		{"Alternative values", []string{"state=stopped,available"}, true},
+		{"All filters must match", []string{"tag:Env=prod", "vpc-id=vpc-999"}, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			filters, err := parseFilters(tt.exprs, rdsFilterAliases)
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if got := matchRDSFilters(inst, filters); got != tt.want {
+				t.Errorf("Expected match=%v, got %v", tt.want, got)
+			}
+		})
+	}
+}
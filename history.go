@@ -0,0 +1,323 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"text/tabwriter"
+	"time"
+)
+
+// historyFileName is the file under appDataDir holding recents and favorites
+const historyFileName = "history.json"
+
+// maxRecents is how many entries are kept besides favorites
+const maxRecents = 50
+
+// History entry kinds
+const (
+	historyKindEC2 = "ec2"
+	historyKindRDS = "rds"
+)
+
+// historyEntry is a target that was connected to. Entries are keyed by kind,
+// profile, region and ID, plus the username for RDS.
+type historyEntry struct {
+	Kind     string    `json:"kind"`
+	Profile  string    `json:"profile,omitempty"`
+	Region   string    `json:"region,omitempty"`
+	ID       string    `json:"id"`
+	Name     string    `json:"name,omitempty"`
+	Username string    `json:"username,omitempty"`
+	Favorite bool      `json:"favorite,omitempty"`
+	LastUsed time.Time `json:"lastUsed"`
+}
+
+func (e historyEntry) sameTarget(other historyEntry) bool {
+	return e.Kind == other.Kind && e.Profile == other.Profile && e.Region == other.Region &&
+		e.ID == other.ID && e.Username == other.Username
+}
+
+// history is the list of recent and favorite targets, most recent first
+type history struct {
+	Entries []historyEntry `json:"entries"`
+}
+
+// appDataDir is where configuration and state are kept
+func appDataDir() string {
+	return filepath.Join(os.Getenv("HOME"), ".aws-go-tools")
+}
+
+func historyPath() string {
+	return filepath.Join(appDataDir(), historyFileName)
+}
+
+// loadHistory reads the history file. A missing or unreadable file gives an
+// empty history, so recents never get in the way of connecting.
+func loadHistory() history {
+	var h history
+
+	data, err := os.ReadFile(historyPath())
+	if err != nil {
+		if !errors.Is(err, os.ErrNotExist) {
+			fmt.Fprintf(os.Stderr, "Warning: could not read history: %v\n", err)
+		}
+		return h
+	}
+	if err := json.Unmarshal(data, &h); err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: ignoring corrupt history file %s: %v\n", historyPath(), err)
+		return history{}
+	}
+
+	h.sort()
+	return h
+}
+
+// save writes the history file
+func (h history) save() error {
+	data, err := json.MarshalIndent(h, "", "  ")
+	if err != nil {
+		return err
+	}
+	return writeFileAtomic(historyPath(), data)
+}
+
+// writeFileAtomic replaces path with data in one step, so concurrent runs
+// never see a partial file. The file and its directory are private to the user.
+func writeFileAtomic(path string, data []byte) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+		return err
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+
+	return os.Rename(tmp.Name(), path)
+}
+
+func (h *history) sort() {
+	sort.SliceStable(h.Entries, func(i, j int) bool {
+		return h.Entries[i].LastUsed.After(h.Entries[j].LastUsed)
+	})
+}
+
+// record adds entry or updates the matching one, keeping its favorite mark,
+// and drops the oldest entries beyond maxRecents that aren't favorites
+func (h *history) record(entry historyEntry) {
+	found := false
+	for i := range h.Entries {
+		if h.Entries[i].sameTarget(entry) {
+			entry.Favorite = h.Entries[i].Favorite
+			h.Entries[i] = entry
+			found = true
+			break
+		}
+	}
+	if !found {
+		h.Entries = append(h.Entries, entry)
+	}
+	h.sort()
+
+	var kept []historyEntry
+	recents := 0
+	for _, e := range h.Entries {
+		if !e.Favorite {
+			if recents >= maxRecents {
+				continue
+			}
+			recents++
+		}
+		kept = append(kept, e)
+	}
+	h.Entries = kept
+}
+
+// lookup returns the most recent entry for a target, with any username
+func (h history) lookup(kind, profile, region, id string) (historyEntry, bool) {
+	for _, e := range h.Entries {
+		if e.Kind == kind && e.Profile == profile && e.Region == region && e.ID == id {
+			return e, true
+		}
+	}
+	return historyEntry{}, false
+}
+
+// setFavorite marks or unmarks every entry whose ID or name is target
+func (h *history) setFavorite(target string, favorite bool) error {
+	found := false
+	for i := range h.Entries {
+		if h.Entries[i].ID == target || h.Entries[i].Name == target {
+			h.Entries[i].Favorite = favorite
+			found = true
+		}
+	}
+	if !found {
+		return fmt.Errorf("%q is not in the history; connect to it first", target)
+	}
+	return nil
+}
+
+// remember records a target in the history file. Failures are only reported.
+func remember(entry historyEntry) {
+	entry.LastUsed = time.Now()
+
+	h := loadHistory()
+	h.record(entry)
+	if err := h.save(); err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: could not save history: %v\n", err)
+	}
+}
+
+func rememberInstance(inst Instance) {
+	remember(historyEntry{Kind: historyKindEC2, Profile: inst.Profile, Region: inst.Region, ID: inst.ID, Name: inst.Name})
+}
+
+func rememberRDS(db RDSInstance, username string) {
+	remember(historyEntry{Kind: historyKindRDS, Profile: db.Profile, Region: db.Region, ID: db.Identifier, Username: username})
+}
+
+// pickerOrder returns the indexes of n picker items with favorites first,
+// then recently used items, most recent first, then the rest in listing order
+func pickerOrder(n int, lookup func(i int) (historyEntry, bool)) []int {
+	order := make([]int, n)
+	for i := range order {
+		order[i] = i
+	}
+
+	rank := func(i int) (int, time.Time) {
+		e, ok := lookup(i)
+		switch {
+		case !ok:
+			return 2, time.Time{}
+		case e.Favorite:
+			return 0, e.LastUsed
+		default:
+			return 1, e.LastUsed
+		}
+	}
+
+	sort.SliceStable(order, func(a, b int) bool {
+		rankA, usedA := rank(order[a])
+		rankB, usedB := rank(order[b])
+		if rankA != rankB {
+			return rankA < rankB
+		}
+		return usedA.After(usedB)
+	})
+	return order
+}
+
+// historyMark prefixes picker options that are favorites or recents
+func historyMark(entry historyEntry, ok bool) string {
+	switch {
+	case !ok:
+		return ""
+	case entry.Favorite:
+		return "★ "
+	default:
+		return "↻ "
+	}
+}
+
+// handleRecent reconnects to the nth most recent target (1 is the last one),
+// using the profile and region it was found in unless they are overridden
+func handleRecent(ctx context.Context, n int) {
+	h := loadHistory()
+	if n < 1 || n > len(h.Entries) {
+		log.Fatalf("No recent target #%d (%d in history); see \"recent list\"", n, len(h.Entries))
+	}
+	entry := h.Entries[n-1]
+
+	if len(awsProfiles()) == 0 && entry.Profile != "" {
+		profiles = []string{entry.Profile}
+	}
+	if region == "" {
+		region = entry.Region
+	}
+	cfg := loadAWSConfig(ctx)
+
+	switch entry.Kind {
+	case historyKindEC2:
+		ec2FilterExprs = []string{"instance-id=" + entry.ID}
+		instances, err := listInstances(ctx, cfg)
+		if err != nil {
+			log.Fatalf("Failed to list instances: %v", err)
+		}
+		selectedInstance, err := resolveInstance(instances, entry.ID)
+		if err != nil {
+			log.Fatalf("Failed to find %s: %v", entry.ID, err)
+		}
+
+		rememberInstance(selectedInstance)
+		if err := connectToInstance(ctx, cfg, selectedInstance); err != nil {
+			fatalSession("Failed to connect to instance: %v", err)
+		}
+
+	case historyKindRDS:
+		rdsFilterExprs = []string{"db-instance-id=" + entry.ID}
+		rdsInstances, err := listRDSInstances(ctx, cfg)
+		if err != nil {
+			log.Fatalf("Failed to list RDS instances: %v", err)
+		}
+		if len(rdsInstances) == 0 {
+			log.Fatalf("RDS instance %s no longer exists", entry.ID)
+		}
+
+		rememberRDS(rdsInstances[0], entry.Username)
+		if err := generateRDSAuthToken(ctx, cfg, rdsInstances[0], entry.Username); err != nil {
+			log.Fatalf("Failed to generate auth token: %v", err)
+		}
+
+	default:
+		log.Fatalf("Unknown history entry kind %q", entry.Kind)
+	}
+}
+
+func handleRecentList() {
+	h := loadHistory()
+	if len(h.Entries) == 0 {
+		fmt.Println("No recent targets")
+		return
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 3, ' ', 0)
+	fmt.Fprintln(w, "#\tKIND\tTARGET\tPROFILE\tREGION\tLAST USED")
+	for i, e := range h.Entries {
+		target := e.ID
+		if e.Name != "" {
+			target = fmt.Sprintf("%s (%s)", e.Name, e.ID)
+		}
+		if e.Username != "" {
+			target = e.Username + "@" + target
+		}
+		fmt.Fprintf(w, "%d\t%s\t%s%s\t%s\t%s\t%s\n",
+			i+1, e.Kind, historyMark(e, true), target, accountLabel(e.Profile, ""), e.Region, e.LastUsed.Local().Format("2006-01-02 15:04"))
+	}
+	w.Flush()
+}
+
+func handleRecentPin(target string, favorite bool) {
+	h := loadHistory()
+	if err := h.setFavorite(target, favorite); err != nil {
+		log.Fatalf("Failed to update favorites: %v", err)
+	}
+	if err := h.save(); err != nil {
+		log.Fatalf("Failed to save history: %v", err)
+	}
+}
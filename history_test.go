@@ -0,0 +1,93 @@
+package main
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestHistoryRecord(t *testing.T) {
+	now := time.Now()
+	h := history{}
+
+	h.record(historyEntry{Kind: historyKindEC2, Region: "us-east-1", ID: "i-1", LastUsed: now.Add(-2 * time.Hour)})
+	h.record(historyEntry{Kind: historyKindEC2, Region: "us-east-1", ID: "i-2", LastUsed: now.Add(-time.Hour)})
+	h.Entries[1].Favorite = true
+
+	// Reconnecting updates the entry in place and keeps it a favorite
+	h.record(historyEntry{Kind: historyKindEC2, Region: "us-east-1", ID: "i-1", Name: "web-1", LastUsed: now})
+
+	if len(h.Entries) != 2 {
+		t.Fatalf("Expected 2 entries, got %d", len(h.Entries))
+	}
+	if h.Entries[0].ID != "i-1" || h.Entries[0].Name != "web-1" || !h.Entries[0].Favorite {
+		t.Errorf("Expected i-1 first, renamed and still a favorite, got %+v", h.Entries[0])
+	}
+
+	// The same instance in another region is another target
+	h.record(historyEntry{Kind: historyKindEC2, Region: "eu-west-1", ID: "i-1", LastUsed: now})
+	if len(h.Entries) != 3 {
+		t.Errorf("Expected 3 entries, got %d", len(h.Entries))
+	}
+}
+
+func TestHistoryRecordLimit(t *testing.T) {
+	start := time.Now()
+	h := history{Entries: []historyEntry{{Kind: historyKindEC2, ID: "i-fav", Favorite: true, LastUsed: start}}}
+
+	for i := 0; i < maxRecents+10; i++ {
+		h.record(historyEntry{Kind: historyKindEC2, ID: fmt.Sprintf("i-%d", i), LastUsed: start.Add(time.Duration(i+1) * time.Minute)})
+	}
+
+	if len(h.Entries) != maxRecents+1 {
+		t.Errorf("Expected %d entries, got %d", maxRecents+1, len(h.Entries))
+	}
+	if _, ok := h.lookup(historyKindEC2, "", "", "i-fav"); !ok {
+		t.Error("Expected the old favorite to be kept")
+	}
+	if _, ok := h.lookup(historyKindEC2, "", "", "i-0"); ok {
+		t.Error("Expected the oldest recent to be dropped")
+	}
+}
+
+func TestHistorySaveAndLoad(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+
+	if h := loadHistory(); len(h.Entries) != 0 {
+		t.Fatalf("Expected an empty history without a file, got %+v", h)
+	}
+
+	remember(historyEntry{Kind: historyKindRDS, Profile: "prod", Region: "us-east-1", ID: "orders", Username: "app"})
+
+	h := loadHistory()
+	entry, ok := h.lookup(historyKindRDS, "prod", "us-east-1", "orders")
+	if !ok || entry.Username != "app" || entry.LastUsed.IsZero() {
+		t.Errorf("Expected the saved entry back, got %+v, %v", entry, ok)
+	}
+
+	if err := h.setFavorite("orders", true); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if err := h.setFavorite("missing", true); err == nil {
+		t.Error("Expected an error for a target that isn't in the history")
+	}
+}
+
+func TestPickerOrder(t *testing.T) {
+	now := time.Now()
+	entries := map[int]historyEntry{
+		1: {LastUsed: now.Add(-time.Hour)},
+		2: {Favorite: true, LastUsed: now.Add(-48 * time.Hour)},
+		4: {LastUsed: now},
+	}
+
+	order := pickerOrder(5, func(i int) (historyEntry, bool) {
+		e, ok := entries[i]
+		return e, ok
+	})
+
+	if want := []int{2, 4, 1, 0, 3}; !reflect.DeepEqual(order, want) {
+		t.Errorf("Expected %v, got %v", want, order)
+	}
+}
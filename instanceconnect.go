@@ -0,0 +1,232 @@
+package main
+
+import (
+	"context"
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"os/exec"
+	"os/signal"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ec2instanceconnect"
+	"golang.org/x/crypto/ssh"
+)
+
+// ephemeralKeyComment labels the keys pushed with EC2 Instance Connect
+const ephemeralKeyComment = "aws-go-tools-ephemeral"
+
+// ephemeralKey is a key pair that only lives for one ssh connection
+type ephemeralKey struct {
+	// PublicKey is in authorized_keys format, as SendSSHPublicKey expects
+	PublicKey string
+	// PrivateKey is the OpenSSH PEM encoding ssh reads with -i
+	PrivateKey []byte
+}
+
+// newEphemeralKey generates an ed25519 key pair in memory
+func newEphemeralKey() (ephemeralKey, error) {
+	public, private, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		return ephemeralKey{}, fmt.Errorf("failed to generate key: %w", err)
+	}
+
+	sshPublic, err := ssh.NewPublicKey(public)
+	if err != nil {
+		return ephemeralKey{}, fmt.Errorf("failed to encode public key: %w", err)
+	}
+	block, err := ssh.MarshalPrivateKey(private, ephemeralKeyComment)
+	if err != nil {
+		return ephemeralKey{}, fmt.Errorf("failed to encode private key: %w", err)
+	}
+
+	return ephemeralKey{
+		PublicKey:  strings.TrimSpace(string(ssh.MarshalAuthorizedKey(sshPublic))),
+		PrivateKey: pem.EncodeToMemory(block),
+	}, nil
+}
+
+// defaultSSHUser guesses the login user of an instance's AMI from its
+// platform
+func defaultSSHUser(instance Instance) string {
+	platform := strings.ToLower(instance.PlatformName + " " + instance.PlatformDetails)
+	switch {
+	case strings.Contains(platform, "ubuntu"):
+		return "ubuntu"
+	case strings.Contains(platform, "debian"):
+		return "admin"
+	}
+	return "ec2-user"
+}
+
+// sshIdentity tells ssh where to find an ephemeral key
+type sshIdentity struct {
+	// KeyFile is passed with -i: the public key when Agent holds the private
+	// key, and the private key itself otherwise
+	KeyFile string
+	// Agent is the socket of an ssh-agent holding the private key, if any
+	Agent string
+}
+
+// sshCommandArgs returns the ssh arguments that log in to the instance as
+// user with only identity, through proxyCommand. extra is passed after the
+// destination, e.g. a remote command or more options.
+func sshCommandArgs(instance Instance, user string, identity sshIdentity, proxyCommand string, extra []string) []string {
+	args := []string{
+		"-i", identity.KeyFile,
+		"-o", "IdentitiesOnly=yes",
+	}
+	if identity.Agent != "" {
+		args = append(args, "-o", "IdentityAgent="+identity.Agent)
+	}
+	args = append(args,
+		"-o", "ProxyCommand="+proxyCommand,
+		user+"@"+instance.ID,
+	)
+	return append(args, extra...)
+}
+
+func handleEC2SSH(ctx context.Context, cfg aws.Config, target, user string, extra []string) {
+	instances, err := listInstances(ctx, cfg)
+	if err != nil {
+		log.Fatalf("Failed to list instances: %v", err)
+	}
+
+	selectedInstance, err := resolveInstance(instances, target)
+	if err != nil {
+		log.Fatalf("Failed to resolve target: %v", err)
+	}
+
+	err = sshToInstance(ctx, cfg, selectedInstance, user, extra)
+
+	// ssh reports its own errors, so only its exit status is passed on
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) {
+		os.Exit(exitErr.ExitCode())
+	}
+	if err != nil {
+		log.Fatalf("Failed to ssh to instance: %v", err)
+	}
+}
+
+// sshToInstance pushes a new key for user with EC2 Instance Connect and runs
+// ssh with it over an AWS-StartSSHSession tunnel. The private key is held by
+// an in-memory ssh-agent (written to a private temporary directory on
+// Windows) only while ssh runs.
+func sshToInstance(ctx context.Context, cfg aws.Config, instance Instance, user string, extra []string) error {
+	cfg = accountConfig(cfg, instance.Profile, instance.Region)
+
+	instance, err := refreshInstance(ctx, cfg, instance)
+	if err != nil {
+		return err
+	}
+
+	instance, err = ensureInstanceRunning(ctx, cfg, instance)
+	if err != nil {
+		return err
+	}
+
+	if err := checkSessionTarget(instance); err != nil {
+		return err
+	}
+
+	if instance.Platform == "windows" {
+		return errors.New("EC2 Instance Connect does not support Windows instances")
+	}
+
+	sshPath, err := exec.LookPath("ssh")
+	if err != nil {
+		return fmt.Errorf("ssh not found in PATH: %w", err)
+	}
+
+	if user == "" {
+		user = defaultSSHUser(instance)
+	}
+
+	key, err := newEphemeralKey()
+	if err != nil {
+		return err
+	}
+
+	dir, err := os.MkdirTemp("", "aws-go-tools-ssh-")
+	if err != nil {
+		return fmt.Errorf("failed to create key directory: %w", err)
+	}
+	defer os.RemoveAll(dir)
+
+	identity, stopAgent, err := loadSSHKey(dir, key)
+	if err != nil {
+		return err
+	}
+	defer stopAgent()
+
+	// The key is accepted for 60 seconds, so it is pushed just before ssh runs
+	if err := pushSSHKey(ctx, cfg, instance, user, key.PublicKey); err != nil {
+		return err
+	}
+
+	proxyCommand := instanceProxyCommand(sshProxyCommand(), instance)
+	if usePlugin {
+		proxyCommand += " --plugin"
+	}
+
+	fmt.Fprintf(os.Stderr, "Connecting to %s (%s) as %s with a temporary key...\n", instance.Name, instance.ID, user)
+
+	cmd := exec.CommandContext(ctx, sshPath, sshCommandArgs(instance, user, identity, proxyCommand, extra)...)
+	cmd.Stdin = os.Stdin
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+
+	// Ctrl-C belongs to ssh and other signals are passed on to it, so this
+	// process outlives ssh and the key is always removed
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, sessionSignals...)
+	defer signal.Stop(signals)
+
+	if err := cmd.Start(); err != nil {
+		return fmt.Errorf("failed to start ssh: %w", err)
+	}
+
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		for {
+			select {
+			case sig := <-signals:
+				if sig != os.Interrupt {
+					_ = cmd.Process.Signal(sig)
+				}
+			case <-done:
+				return
+			}
+		}
+	}()
+
+	return cmd.Wait()
+}
+
+// pushSSHKey makes publicKey valid for user on the instance for 60 seconds
+func pushSSHKey(ctx context.Context, cfg aws.Config, instance Instance, user, publicKey string) error {
+	input := &ec2instanceconnect.SendSSHPublicKeyInput{
+		InstanceId:     aws.String(instance.ID),
+		InstanceOSUser: aws.String(user),
+		SSHPublicKey:   aws.String(publicKey),
+	}
+	if instance.AvailabilityZone != "" {
+		input.AvailabilityZone = aws.String(instance.AvailabilityZone)
+	}
+
+	output, err := ec2instanceconnect.NewFromConfig(cfg).SendSSHPublicKey(ctx, input)
+	if err != nil {
+		return fmt.Errorf("failed to send SSH public key: %w", err)
+	}
+	if !output.Success {
+		return fmt.Errorf("EC2 Instance Connect did not accept the key for %s", user)
+	}
+	return nil
+}
@@ -0,0 +1,131 @@
+package main
+
+import (
+	"net"
+	"os"
+	"path/filepath"
+	"reflect"
+	"runtime"
+	"strings"
+	"testing"
+
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/agent"
+)
+
+func TestNewEphemeralKey(t *testing.T) {
+	key, err := newEphemeralKey()
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	if !strings.HasPrefix(key.PublicKey, "ssh-ed25519 ") || strings.Contains(key.PublicKey, "\n") {
+		t.Errorf("Expected a single-line ssh-ed25519 public key, got %q", key.PublicKey)
+	}
+
+	signer, err := ssh.ParsePrivateKey(key.PrivateKey)
+	if err != nil {
+		t.Fatalf("Expected ssh to parse the private key, got %v", err)
+	}
+	public := strings.TrimSpace(string(ssh.MarshalAuthorizedKey(signer.PublicKey())))
+	if public != key.PublicKey {
+		t.Errorf("Expected the private key to match the public key %q, got %q", key.PublicKey, public)
+	}
+
+	other, err := newEphemeralKey()
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if other.PublicKey == key.PublicKey {
+		t.Errorf("Expected a new key pair every time")
+	}
+}
+
+func TestDefaultSSHUser(t *testing.T) {
+	tests := []struct {
+		instance Instance
+		want     string
+	}{
+		{Instance{PlatformName: "Ubuntu", PlatformDetails: "Linux/UNIX"}, "ubuntu"},
+		{Instance{PlatformName: "Debian GNU/Linux"}, "admin"},
+		{Instance{PlatformName: "Amazon Linux", PlatformDetails: "Linux/UNIX"}, "ec2-user"},
+		{Instance{PlatformDetails: "Red Hat Enterprise Linux"}, "ec2-user"},
+		{Instance{}, "ec2-user"},
+	}
+
+	for _, tt := range tests {
+		if got := defaultSSHUser(tt.instance); got != tt.want {
+			t.Errorf("defaultSSHUser(%q, %q): expected %q, got %q", tt.instance.PlatformName, tt.instance.PlatformDetails, tt.want, got)
+		}
+	}
+}
+
+func TestSSHCommandArgs(t *testing.T) {
+	instance := Instance{ID: "i-0123456789abcdef0", Profile: "prod", Region: "eu-west-1"}
+	proxyCommand := instanceProxyCommand("/usr/local/bin/aws-go-tools ec2 ssh-proxy %h %p", instance)
+
+	identity := sshIdentity{KeyFile: "/tmp/key/id_ed25519.pub", Agent: "/tmp/key/agent.sock"}
+	got := sshCommandArgs(instance, "ec2-user", identity, proxyCommand, []string{"-L", "8080:localhost:80", "uptime"})
+	want := []string{
+		"-i", "/tmp/key/id_ed25519.pub",
+		"-o", "IdentitiesOnly=yes",
+		"-o", "IdentityAgent=/tmp/key/agent.sock",
+		"-o", "ProxyCommand=/usr/local/bin/aws-go-tools ec2 ssh-proxy %h %p --profile prod --region eu-west-1",
+		"ec2-user@i-0123456789abcdef0",
+		"-L", "8080:localhost:80", "uptime",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Expected %q, got %q", want, got)
+	}
+
+	// Without an agent, ssh reads the private key file
+	got = sshCommandArgs(instance, "ec2-user", sshIdentity{KeyFile: "/tmp/key/id_ed25519"}, proxyCommand, nil)
+	if got[2] != "-o" || got[3] != "IdentitiesOnly=yes" || strings.Contains(strings.Join(got, " "), "IdentityAgent") {
+		t.Errorf("Expected no IdentityAgent without an agent, got %q", got)
+	}
+}
+
+func TestLoadSSHKey(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("the key is written to a file on Windows")
+	}
+
+	key, err := newEphemeralKey()
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	dir := t.TempDir()
+	identity, stop, err := loadSSHKey(dir, key)
+	if err != nil {
+		t.Fatalf("Expected the key to be loaded, got %v", err)
+	}
+	defer stop()
+
+	public, err := os.ReadFile(identity.KeyFile)
+	if err != nil || strings.TrimSpace(string(public)) != key.PublicKey {
+		t.Errorf("Expected the public key file for -i, got %q, %v", public, err)
+	}
+
+	// The agent holds the only copy of the private key
+	entries, _ := os.ReadDir(dir)
+	for _, entry := range entries {
+		if data, _ := os.ReadFile(filepath.Join(dir, entry.Name())); strings.Contains(string(data), "PRIVATE KEY") {
+			t.Errorf("Expected no private key on disk, found %s", entry.Name())
+		}
+	}
+
+	conn, err := net.Dial("unix", identity.Agent)
+	if err != nil {
+		t.Fatalf("Expected to connect to the agent, got %v", err)
+	}
+	defer conn.Close()
+
+	keys, err := agent.NewClient(conn).List()
+	if err != nil || len(keys) != 1 {
+		t.Fatalf("Expected one key in the agent, got %v, %v", keys, err)
+	}
+	if listed := strings.TrimSpace(string(ssh.MarshalAuthorizedKey(keys[0]))); listed != key.PublicKey {
+		t.Errorf("Expected the agent to hold %q, got %q", key.PublicKey, listed)
+	}
+}
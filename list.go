@@ -0,0 +1,181 @@
+package main
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"gopkg.in/yaml.v3"
+)
+
+// Output formats of the list commands
+const (
+	outputTable = "table"
+	outputWide  = "wide"
+	outputJSON  = "json"
+	outputYAML  = "yaml"
+	outputCSV   = "csv"
+)
+
+var outputFormats = []string{outputTable, outputWide, outputJSON, outputYAML, outputCSV}
+
+// validateOutputFormat checks --output before anything is listed
+func validateOutputFormat(format string) error {
+	for _, f := range outputFormats {
+		if format == f {
+			return nil
+		}
+	}
+	return fmt.Errorf("unknown output format %q (use %s)", format, strings.Join(outputFormats, ", "))
+}
+
+// instanceCSVHeader matches the JSON field names of Instance
+var instanceCSVHeader = []string{"id", "name", "profile", "accountId", "region", "privateIp", "publicIp", "state",
+	"instanceType", "platform", "vpcId", "tags", "availabilityZone", "launchTime", "imageId", "platformDetails",
+	"pingStatus", "agentVersion", "platformName", "lastPingTime"}
+
+func instanceCSVRecord(inst Instance) []string {
+	return []string{inst.ID, inst.Name, inst.Profile, inst.AccountID, inst.Region, inst.PrivateIP, inst.PublicIP, inst.State,
+		inst.InstanceType, inst.Platform, inst.VpcID, strings.Join(tagPairs(inst.Tags), ";"), inst.AvailabilityZone, csvTime(inst.LaunchTime),
+		inst.ImageID, inst.PlatformDetails, inst.PingStatus, inst.AgentVersion, inst.PlatformName, csvTime(inst.LastPingTime)}
+}
+
+// rdsCSVHeader matches the JSON field names of RDSInstance
+var rdsCSVHeader = []string{"identifier", "profile", "accountId", "region", "endpoint", "port", "engine", "engineVersion",
+	"status", "instanceClass", "vpcId", "tags", "availabilityZone"}
+
+func rdsCSVRecord(inst RDSInstance) []string {
+	return []string{inst.Identifier, inst.Profile, inst.AccountID, inst.Region, inst.Endpoint, strconv.Itoa(int(inst.Port)), inst.Engine, inst.EngineVersion,
+		inst.Status, inst.InstanceClass, inst.VpcID, strings.Join(tagPairs(inst.Tags), ";"), inst.AvailabilityZone}
+}
+
+// csvTime formats t as RFC 3339, leaving unknown times empty
+func csvTime(t time.Time) string {
+	if t.IsZero() {
+		return ""
+	}
+	return t.UTC().Format(time.RFC3339)
+}
+
+// orDash fills empty table cells
+func orDash(s string) string {
+	if s == "" {
+		return "-"
+	}
+	return s
+}
+
+// writeInstances writes instances to w in the given output format
+func writeInstances(w io.Writer, instances []Instance, format string) error {
+	switch format {
+	case outputTable, outputWide:
+		displayInstances(w, instances, format == outputWide)
+		return nil
+	case outputCSV:
+		return writeCSV(w, instanceCSVHeader, instances, instanceCSVRecord)
+	default:
+		return writeStructured(w, instances, format)
+	}
+}
+
+// writeRDSInstances writes RDS instances to w in the given output format
+func writeRDSInstances(w io.Writer, instances []RDSInstance, format string) error {
+	switch format {
+	case outputTable, outputWide:
+		displayRDSInstances(w, instances, format == outputWide)
+		return nil
+	case outputCSV:
+		return writeCSV(w, rdsCSVHeader, instances, rdsCSVRecord)
+	default:
+		return writeStructured(w, instances, format)
+	}
+}
+
+// writeStructured writes items as a JSON or YAML list. An empty listing is
+// an empty list rather than null, so scripts don't need to special-case it.
+func writeStructured[T any](w io.Writer, items []T, format string) error {
+	if items == nil {
+		items = []T{}
+	}
+
+	switch format {
+	case outputJSON:
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		return enc.Encode(items)
+	case outputYAML:
+		enc := yaml.NewEncoder(w)
+		enc.SetIndent(2)
+		if err := enc.Encode(items); err != nil {
+			return err
+		}
+		return enc.Close()
+	default:
+		return validateOutputFormat(format)
+	}
+}
+
+// writeCSV writes a header row and one record per item
+func writeCSV[T any](w io.Writer, header []string, items []T, record func(T) []string) error {
+	cw := csv.NewWriter(w)
+	if err := cw.Write(header); err != nil {
+		return err
+	}
+	for _, item := range items {
+		if err := cw.Write(record(item)); err != nil {
+			return err
+		}
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+// handleEC2List prints the listed instances with tmpl, or in format when
+// tmpl is nil
+func handleEC2List(ctx context.Context, cfg aws.Config, format string, tmpl *listTemplate) {
+	instances, err := listInstances(ctx, cfg)
+	if err != nil {
+		log.Fatalf("Failed to list instances: %v", err)
+	}
+
+	if tmpl != nil {
+		err = writeTemplate(os.Stdout, tmpl, instances, func(inst Instance) map[string]string { return inst.Tags })
+	} else if len(instances) == 0 && (format == outputTable || format == outputWide) {
+		fmt.Fprintln(os.Stderr, "No EC2 instances found")
+		return
+	} else {
+		err = writeInstances(os.Stdout, instances, format)
+	}
+	if err != nil {
+		log.Fatalf("Failed to write instances: %v", err)
+	}
+}
+
+// handleRDSList prints the listed RDS instances with tmpl, or in format
+// when tmpl is nil
+func handleRDSList(ctx context.Context, cfg aws.Config, format string, tmpl *listTemplate) {
+	instances, err := listRDSInstances(ctx, cfg)
+	if err != nil {
+		log.Fatalf("Failed to list RDS instances: %v", err)
+	}
+
+	if tmpl != nil {
+		err = writeTemplate(os.Stdout, tmpl, instances, func(inst RDSInstance) map[string]string { return inst.Tags })
+	} else if len(instances) == 0 && (format == outputTable || format == outputWide) {
+		fmt.Fprintln(os.Stderr, "No RDS instances found")
+		return
+	} else {
+		err = writeRDSInstances(os.Stdout, instances, format)
+	}
+	if err != nil {
+		log.Fatalf("Failed to write RDS instances: %v", err)
+	}
+}
@@ -0,0 +1,148 @@
+package main
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+var listTestInstances = []Instance{
+	{
+		ID:           "i-0123456789abcdef0",
+		Name:         "web-1",
+		Region:       "us-east-1",
+		PrivateIP:    "10.0.0.1",
+		State:        "running",
+		InstanceType: "t3.micro",
+		Platform:     "linux",
+		Tags:         map[string]string{"Name": "web-1", "Env": "prod"},
+		LaunchTime:   time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC),
+		PingStatus:   "Online",
+	},
+	{ID: "i-0fedcba9876543210", State: "stopped", InstanceType: "t3.large"},
+}
+
+func TestValidateOutputFormat(t *testing.T) {
+	tests := []struct {
+		format  string
+		wantErr bool
+	}{
+		{"table", false},
+		{"wide", false},
+		{"json", false},
+		{"yaml", false},
+		{"csv", false},
+		{"xml", true},
+		{"", true},
+	}
+
+	for _, tt := range tests {
+		err := validateOutputFormat(tt.format)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("Expected error %v for %q, got %v", tt.wantErr, tt.format, err)
+		}
+	}
+}
+
+func TestWriteInstancesJSON(t *testing.T) {
+	var buf bytes.Buffer
+	if err := writeInstances(&buf, listTestInstances, outputJSON); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	var decoded []map[string]any
+	if err := json.Unmarshal(buf.Bytes(), &decoded); err != nil {
+		t.Fatalf("Expected valid JSON, got %v", err)
+	}
+	if len(decoded) != 2 {
+		t.Fatalf("Expected 2 instances, got %d", len(decoded))
+	}
+	for _, key := range []string{"id", "name", "privateIp", "instanceType", "pingStatus", "launchTime", "tags"} {
+		if _, ok := decoded[0][key]; !ok {
+			t.Errorf("Expected JSON key %q, got %v", key, decoded[0])
+		}
+	}
+	if decoded[0]["id"] != "i-0123456789abcdef0" || decoded[0]["launchTime"] != "2024-01-02T03:04:05Z" {
+		t.Errorf("Expected the instance's fields, got %v", decoded[0])
+	}
+}
+
+func TestWriteInstancesEmptyJSON(t *testing.T) {
+	var buf bytes.Buffer
+	if err := writeInstances(&buf, nil, outputJSON); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if got := strings.TrimSpace(buf.String()); got != "[]" {
+		t.Errorf("Expected an empty list, got %q", got)
+	}
+}
+
+func TestWriteRDSInstancesYAML(t *testing.T) {
+	instances := []RDSInstance{{Identifier: "orders", Engine: "postgres", Port: 5432, Status: "available"}}
+
+	var buf bytes.Buffer
+	if err := writeRDSInstances(&buf, instances, outputYAML); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	var decoded []RDSInstance
+	if err := yaml.Unmarshal(buf.Bytes(), &decoded); err != nil {
+		t.Fatalf("Expected valid YAML, got %v", err)
+	}
+	if len(decoded) != 1 || decoded[0].Identifier != "orders" || decoded[0].Port != 5432 {
+		t.Errorf("Expected the RDS instance back, got %+v", decoded)
+	}
+	if !strings.Contains(buf.String(), "engineVersion:") {
+		t.Errorf("Expected camelCase YAML keys, got %s", buf.String())
+	}
+}
+
+func TestWriteInstancesCSV(t *testing.T) {
+	var buf bytes.Buffer
+	if err := writeInstances(&buf, listTestInstances, outputCSV); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	records, err := csv.NewReader(&buf).ReadAll()
+	if err != nil {
+		t.Fatalf("Expected valid CSV, got %v", err)
+	}
+	if len(records) != 3 {
+		t.Fatalf("Expected a header and 2 rows, got %d", len(records))
+	}
+	for i, record := range records {
+		if len(record) != len(instanceCSVHeader) {
+			t.Errorf("Expected %d columns in row %d, got %d", len(instanceCSVHeader), i, len(record))
+		}
+	}
+	if records[1][0] != "i-0123456789abcdef0" || records[1][11] != "Env=prod;Name=web-1" || records[1][13] != "2024-01-02T03:04:05Z" {
+		t.Errorf("Expected the instance's fields, got %v", records[1])
+	}
+	if records[2][13] != "" {
+		t.Errorf("Expected an unknown launch time to be empty, got %q", records[2][13])
+	}
+}
+
+func TestWriteInstancesTable(t *testing.T) {
+	var buf bytes.Buffer
+	if err := writeInstances(&buf, listTestInstances, outputTable); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if len(lines) != 3 || !strings.HasPrefix(lines[0], "INSTANCE ID") || strings.Contains(lines[0], "LAUNCHED") {
+		t.Errorf("Expected a header and 2 rows without wide columns, got %q", buf.String())
+	}
+
+	buf.Reset()
+	if err := writeInstances(&buf, listTestInstances, outputWide); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if !strings.Contains(buf.String(), "LAUNCHED") || !strings.Contains(buf.String(), "Online") {
+		t.Errorf("Expected the wide columns, got %q", buf.String())
+	}
+}
@@ -3,11 +3,14 @@ package main
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
 	"log"
 	"os"
 	"os/exec"
 	"path/filepath"
+	"strconv"
 	"strings"
 	"text/tabwriter"
 	"time"
@@ -19,8 +22,11 @@ import (
 	"github.com/aws/aws-sdk-go-v2/service/ec2"
 	"github.com/aws/aws-sdk-go-v2/service/ec2/types"
 	"github.com/aws/aws-sdk-go-v2/service/rds"
+	rdstypes "github.com/aws/aws-sdk-go-v2/service/rds/types"
 	"github.com/aws/aws-sdk-go-v2/service/ssm"
+	ssmtypes "github.com/aws/aws-sdk-go-v2/service/ssm/types"
 	"github.com/spf13/cobra"
+	"golang.org/x/term"
 	"gopkg.in/yaml.v3"
 )
 
@@ -29,26 +35,95 @@ var Version = "dev"
 
 // Global flags
 var (
-	profile string
-	region  string
+	profiles       []string
+	profileList    []string
+	region         string
+	regionList     []string
+	allRegions     bool
+	maxResults     int
+	ec2FilterExprs []string
+	rdsFilterExprs []string
+	remotePort     int
+	localPort      int
+	bastionTarget  string
+
+	execTargets     []string
+	execConcurrency int
+	execTimeout     time.Duration
+	assumeYes       bool
+
+	startStopped bool
+	startTimeout time.Duration
+
+	cacheTTL     time.Duration
+	refreshCache bool
+	offline      bool
+
+	outputFormat   string
+	formatTemplate string
+
+	recordSession bool
+	recordInput   bool
+
+	usePlugin          bool
+	maxSessionDuration time.Duration
+
+	documentName   string
+	documentParams []string
+	runAsUser      string
 )
 
 type Instance struct {
-	ID           string
-	Name         string
-	PrivateIP    string
-	PublicIP     string
-	State        string
-	InstanceType string
-	Platform     string
+	ID           string            `json:"id" yaml:"id"`
+	Name         string            `json:"name" yaml:"name"`
+	Profile      string            `json:"profile" yaml:"profile"`
+	AccountID    string            `json:"accountId" yaml:"accountId"`
+	Region       string            `json:"region" yaml:"region"`
+	PrivateIP    string            `json:"privateIp" yaml:"privateIp"`
+	PublicIP     string            `json:"publicIp" yaml:"publicIp"`
+	State        string            `json:"state" yaml:"state"`
+	InstanceType string            `json:"instanceType" yaml:"instanceType"`
+	Platform     string            `json:"platform" yaml:"platform"`
+	VpcID        string            `json:"vpcId" yaml:"vpcId"`
+	Tags         map[string]string `json:"tags" yaml:"tags"`
+
+	AvailabilityZone string    `json:"availabilityZone" yaml:"availabilityZone"`
+	LaunchTime       time.Time `json:"launchTime" yaml:"launchTime"`
+	ImageID          string    `json:"imageId" yaml:"imageId"`
+	// PlatformDetails is the EC2 billing platform, e.g. "Linux/UNIX" or "Red Hat Enterprise Linux"
+	PlatformDetails string `json:"platformDetails" yaml:"platformDetails"`
+
+	// SSM agent details, empty when they could not be looked up
+	PingStatus   string    `json:"pingStatus" yaml:"pingStatus"`
+	AgentVersion string    `json:"agentVersion" yaml:"agentVersion"`
+	PlatformName string    `json:"platformName" yaml:"platformName"`
+	LastPingTime time.Time `json:"lastPingTime" yaml:"lastPingTime"`
+}
+
+// pingStatusNotManaged marks instances that aren't registered with Systems Manager
+const pingStatusNotManaged = "NotManaged"
+
+// ssmReachable reports whether the SSM agent on the instance is online. An
+// unknown status is treated as reachable and left for StartSession to verify.
+func (i Instance) ssmReachable() bool {
+	return i.PingStatus == "" || i.PingStatus == string(ssmtypes.PingStatusOnline)
 }
 
 type RDSInstance struct {
-	Identifier string
-	Endpoint   string
-	Port       int32
-	Engine     string
-	Status     string
+	Identifier    string            `json:"identifier" yaml:"identifier"`
+	Profile       string            `json:"profile" yaml:"profile"`
+	AccountID     string            `json:"accountId" yaml:"accountId"`
+	Region        string            `json:"region" yaml:"region"`
+	Endpoint      string            `json:"endpoint" yaml:"endpoint"`
+	Port          int32             `json:"port" yaml:"port"`
+	Engine        string            `json:"engine" yaml:"engine"`
+	EngineVersion string            `json:"engineVersion" yaml:"engineVersion"`
+	Status        string            `json:"status" yaml:"status"`
+	InstanceClass string            `json:"instanceClass" yaml:"instanceClass"`
+	VpcID         string            `json:"vpcId" yaml:"vpcId"`
+	Tags          map[string]string `json:"tags" yaml:"tags"`
+
+	AvailabilityZone string `json:"availabilityZone" yaml:"availabilityZone"`
 }
 
 // SessionData represents the data structure for SSM session manager plugin
@@ -62,11 +137,42 @@ type SessionData struct {
 type Config struct {
 	Linux   ShellConfig `yaml:"linux"`
 	Windows ShellConfig `yaml:"windows"`
+	// Rules override the linux/windows defaults for matching instances; the
+	// first match wins
+	Rules     []ShellRule     `yaml:"rules"`
+	Cache     CacheConfig     `yaml:"cache"`
+	Recording RecordingConfig `yaml:"recording"`
 }
 
 // ShellConfig represents shell configuration for a platform
 type ShellConfig struct {
 	Shell string `yaml:"shell"`
+	// Shells is a preference list, e.g. [zsh, bash, sh]. When set, the
+	// instance is probed for the first one installed, falling back to Shell.
+	Shells []string `yaml:"shells"`
+	// Document is the SSM session document to start (default: AWS-StartInteractiveCommand)
+	Document string `yaml:"document"`
+	// Parameters are the document's parameters. Values are Go templates,
+	// e.g. "{{.Instance.Name}}" or "{{.Shell}}".
+	Parameters map[string]string `yaml:"parameters"`
+	// RunAs starts the shell as this OS user with sudo
+	RunAs string `yaml:"runAs"`
+	// WorkingDirectory is where the shell starts
+	WorkingDirectory string `yaml:"workingDirectory"`
+}
+
+// CacheConfig controls the local inventory cache
+type CacheConfig struct {
+	// TTL is how long cached listings are used; 0 disables the cache
+	TTL time.Duration `yaml:"ttl"`
+}
+
+// RecordingConfig controls local recording of EC2 shell sessions
+type RecordingConfig struct {
+	// Enabled records every shell session, as if --record were given
+	Enabled bool `yaml:"enabled"`
+	// Input also records keystrokes, which may include passwords
+	Input bool `yaml:"input"`
 }
 
 // Global configuration
@@ -96,6 +202,7 @@ func main() {
 		Use:   "ec2",
 		Short: "Connect to EC2 instance via SSM",
 		Long:  `List EC2 instances and connect to the selected instance using AWS Systems Manager Session Manager.`,
+		Args:  cobra.NoArgs,
 		Run: func(cmd *cobra.Command, args []string) {
 			ctx := context.Background()
 			cfg := loadAWSConfig(ctx)
@@ -103,11 +210,154 @@ func main() {
 		},
 	}
 
+	// EC2 connect command
+	ec2ConnectCmd := &cobra.Command{
+		Use:   "connect <target>",
+		Short: "Connect to an EC2 instance by ID, Name tag or glob pattern",
+		Long: `Connect to an EC2 instance without the interactive picker. The target is matched
+as an instance ID, then as an exact Name tag, then as a glob pattern (e.g. "web-*").
+The picker is only shown when several instances match.`,
+		Args: cobra.ExactArgs(1),
+		Run: func(cmd *cobra.Command, args []string) {
+			ctx := context.Background()
+			cfg := loadAWSConfig(ctx)
+			handleEC2Connect(ctx, cfg, args[0])
+		},
+	}
+
+	// EC2 port-forward command
+	ec2PortForwardCmd := &cobra.Command{
+		Use:   "port-forward <target>",
+		Short: "Forward a local port to a port on an EC2 instance via SSM",
+		Long: `Forward a local port to a port on an EC2 instance using the AWS-StartPortForwardingSession
+document. The target is resolved like "ec2 connect". A free local port is chosen when
+--local-port is not given.`,
+		Args: cobra.ExactArgs(1),
+		Run: func(cmd *cobra.Command, args []string) {
+			ctx := context.Background()
+			cfg := loadAWSConfig(ctx)
+			handleEC2PortForward(ctx, cfg, args[0])
+		},
+	}
+	ec2PortForwardCmd.Flags().IntVar(&remotePort, "remote-port", 0, "Port on the instance to forward to (required)")
+	ec2PortForwardCmd.Flags().IntVar(&localPort, "local-port", 0, "Local port to listen on (default: a free port)")
+	ec2PortForwardCmd.MarkFlagRequired("remote-port")
+
+	// EC2 exec command
+	ec2ExecCmd := &cobra.Command{
+		Use:   "exec [--filter name=value | --target <target>]... -- <command>",
+		Short: "Run a shell command on several EC2 instances via SSM Run Command",
+		Long: `Run a command on every instance selected by --filter and/or --target using SSM Run
+Command (AWS-RunShellScript on Linux, AWS-RunPowerShellScript on Windows). Output is
+printed per host, prefixed with the instance name, followed by a summary. The exit
+status is non-zero if the command failed on any host or a host was skipped.
+
+Several arguments are quoted so each reaches the command unchanged; a single argument
+is passed to the shell as written, so it can contain pipes and redirections.`,
+		Example: `  aws-go-tools ec2 exec --filter tag:Role=web -- uptime
+  aws-go-tools ec2 exec --target 'web-*' --concurrency 5 -- sudo systemctl restart nginx
+  aws-go-tools ec2 exec --target 'web-*' -- 'journalctl -u nginx | tail -n 20'`,
+		Args: cobra.MinimumNArgs(1),
+		Run: func(cmd *cobra.Command, args []string) {
+			ctx := context.Background()
+			cfg := loadAWSConfig(ctx)
+			handleEC2Exec(ctx, cfg, args)
+		},
+	}
+	ec2ExecCmd.Flags().StringArrayVar(&execTargets, "target", nil, "Instance ID, Name tag or glob pattern to run on (repeatable)")
+	ec2ExecCmd.Flags().IntVar(&execConcurrency, "concurrency", 10, "Maximum number of hosts to run on and poll at the same time")
+	ec2ExecCmd.Flags().DurationVar(&execTimeout, "timeout", 10*time.Minute, "Cancel the command on hosts that haven't finished after this long")
+	ec2ExecCmd.Flags().BoolVarP(&assumeYes, "yes", "y", false, "Don't ask for confirmation")
+
+	// EC2 ssh-proxy command
+	ec2SSHProxyCmd := &cobra.Command{
+		Use:   "ssh-proxy <host> <port>",
+		Short: "OpenSSH ProxyCommand that tunnels SSH over SSM",
+		Long: `Start an AWS-StartSSHSession session to the instance and wire it to stdin/stdout, for use
+as an OpenSSH ProxyCommand. The host is an instance ID or Name tag.`,
+		Example: `  ssh -o ProxyCommand='aws-go-tools ec2 ssh-proxy %h %p' ec2-user@i-0123456789abcdef0`,
+		Args:    cobra.ExactArgs(2),
+		Run: func(cmd *cobra.Command, args []string) {
+			ctx := context.Background()
+			cfg := loadAWSConfig(ctx)
+			handleEC2SSHProxy(ctx, cfg, args[0], args[1])
+		},
+	}
+
+	// EC2 ssh-config command
+	var sshUser, sshHostPrefix string
+	ec2SSHConfigCmd := &cobra.Command{
+		Use:   "ssh-config",
+		Short: "Print ssh_config Host blocks that connect over SSM",
+		Long: `Print an ssh_config Host block for every listed Linux instance, using "ec2 ssh-proxy" as the
+ProxyCommand, so ssh, scp, rsync and VS Code Remote can reach private instances without a
+bastion. Instances are reachable by instance ID and by Name tag when it is unique.`,
+		Example: `  aws-go-tools ec2 ssh-config --filter tag:Env=dev --user ec2-user > ~/.ssh/config.d/aws-dev
+  echo 'Include config.d/*' >> ~/.ssh/config`,
+		Args: cobra.NoArgs,
+		Run: func(cmd *cobra.Command, args []string) {
+			ctx := context.Background()
+			cfg := loadAWSConfig(ctx)
+			handleEC2SSHConfig(ctx, cfg, sshUser, sshHostPrefix)
+		},
+	}
+	ec2SSHConfigCmd.Flags().StringVar(&sshUser, "user", "", "SSH user to set on every host (e.g. ec2-user, ubuntu)")
+	ec2SSHConfigCmd.Flags().StringVar(&sshHostPrefix, "prefix", "", "Prefix for every Host alias (e.g. prod-)")
+
+	// EC2 ssh command
+	ec2SSHCmd := &cobra.Command{
+		Use:   "ssh <target> [-- <ssh arguments>...]",
+		Short: "SSH to an EC2 instance with a temporary EC2 Instance Connect key",
+		Long: `Generate a key pair for this connection only, push the public key to the instance with EC2
+Instance Connect and run ssh with it through "ec2 ssh-proxy". The key is valid for 60 seconds
+and no key pair needs to be installed on the instance. The target is resolved like
+"ec2 connect", and arguments after -- are passed to ssh after the destination.`,
+		Example: `  aws-go-tools ec2 ssh web-1
+  aws-go-tools ec2 ssh web-1 --user ubuntu -- -L 8080:localhost:80
+  aws-go-tools ec2 ssh i-0123456789abcdef0 -- sudo systemctl status nginx`,
+		Args: cobra.MinimumNArgs(1),
+		Run: func(cmd *cobra.Command, args []string) {
+			ctx := context.Background()
+			cfg := loadAWSConfig(ctx)
+			handleEC2SSH(ctx, cfg, args[0], sshUser, args[1:])
+		},
+	}
+	ec2SSHCmd.Flags().StringVar(&sshUser, "user", "", "OS user to log in as (default: ubuntu or admin for Ubuntu and Debian, else ec2-user)")
+
+	// EC2 list command
+	ec2ListCmd := &cobra.Command{
+		Use:   "list",
+		Short: "List EC2 instances without connecting",
+		Long: `Print the listed EC2 instances as a table, or as JSON, YAML or CSV for scripts. The
+structured formats include every field, named like the JSON keys.`,
+		Example: `  aws-go-tools ec2 list --filter tag:Env=prod
+  aws-go-tools ec2 list -o json | jq -r '.[] | select(.pingStatus == "Online") | .id'`,
+		Args: cobra.NoArgs,
+		Run: func(cmd *cobra.Command, args []string) {
+			if err := validateOutputFormat(outputFormat); err != nil {
+				log.Fatalf("Invalid --output: %v", err)
+			}
+			var tmpl *listTemplate
+			if formatTemplate != "" {
+				var err error
+				if tmpl, err = parseListTemplate(formatTemplate); err != nil {
+					log.Fatalf("Invalid --format: %v", err)
+				}
+			}
+			ctx := context.Background()
+			cfg := loadAWSConfig(ctx)
+			handleEC2List(ctx, cfg, outputFormat, tmpl)
+		},
+	}
+
+	ec2Cmd.AddCommand(ec2ConnectCmd, ec2PortForwardCmd, ec2ExecCmd, ec2SSHCmd, ec2SSHProxyCmd, ec2SSHConfigCmd, ec2ListCmd)
+
 	// RDS command
 	rdsCmd := &cobra.Command{
 		Use:   "rds",
 		Short: "Generate RDS IAM authentication token",
 		Long:  `List RDS instances and generate an IAM authentication token for the selected instance.`,
+		Args:  cobra.NoArgs,
 		Run: func(cmd *cobra.Command, args []string) {
 			ctx := context.Background()
 			cfg := loadAWSConfig(ctx)
@@ -115,12 +365,229 @@ func main() {
 		},
 	}
 
+	// RDS tunnel command
+	rdsTunnelCmd := &cobra.Command{
+		Use:   "tunnel",
+		Short: "Tunnel to a private RDS instance through an SSM bastion",
+		Long: `Select an RDS instance and open an AWS-StartPortForwardingSessionToRemoteHost session
+through an EC2 bastion in the same VPC, then print an IAM auth token and connection
+examples pointing at the local end of the tunnel. The bastion is discovered
+automatically unless --bastion is given.`,
+		Args: cobra.NoArgs,
+		Run: func(cmd *cobra.Command, args []string) {
+			ctx := context.Background()
+			cfg := loadAWSConfig(ctx)
+			handleRDSTunnel(ctx, cfg)
+		},
+	}
+	rdsTunnelCmd.Flags().StringVar(&bastionTarget, "bastion", "", "Bastion instance ID, Name tag or glob pattern (default: discover one in the database's VPC)")
+	rdsTunnelCmd.Flags().IntVar(&localPort, "local-port", 0, "Local port to listen on (default: a free port)")
+
+	// RDS list command
+	rdsListCmd := &cobra.Command{
+		Use:   "list",
+		Short: "List RDS instances without generating a token",
+		Long: `Print the listed RDS instances as a table, or as JSON, YAML or CSV for scripts. The
+structured formats include every field, named like the JSON keys.`,
+		Example: `  aws-go-tools rds list --filter engine=postgres -o csv > databases.csv`,
+		Args:    cobra.NoArgs,
+		Run: func(cmd *cobra.Command, args []string) {
+			if err := validateOutputFormat(outputFormat); err != nil {
+				log.Fatalf("Invalid --output: %v", err)
+			}
+			var tmpl *listTemplate
+			if formatTemplate != "" {
+				var err error
+				if tmpl, err = parseListTemplate(formatTemplate); err != nil {
+					log.Fatalf("Invalid --format: %v", err)
+				}
+			}
+			ctx := context.Background()
+			cfg := loadAWSConfig(ctx)
+			handleRDSList(ctx, cfg, outputFormat, tmpl)
+		},
+	}
+
+	rdsCmd.AddCommand(rdsTunnelCmd, rdsListCmd)
+
+	// Recent command
+	recentCmd := &cobra.Command{
+		Use:   "recent [n]",
+		Short: "Reconnect to a recently used EC2 instance or RDS database",
+		Long: `Reconnect to the nth most recent target (default: the last one) with the profile and
+region it was found in. EC2 instances get a shell; RDS databases get a new IAM auth token
+for the same username. Targets are recorded in ~/.aws-go-tools/history.json.`,
+		Args: cobra.MaximumNArgs(1),
+		Run: func(cmd *cobra.Command, args []string) {
+			n := 1
+			if len(args) == 1 {
+				var err error
+				if n, err = strconv.Atoi(args[0]); err != nil {
+					log.Fatalf("Invalid target number %q", args[0])
+				}
+			}
+			handleRecent(context.Background(), n)
+		},
+	}
+
+	recentListCmd := &cobra.Command{
+		Use:   "list",
+		Short: "List recent and favorite targets",
+		Args:  cobra.NoArgs,
+		Run: func(cmd *cobra.Command, args []string) {
+			handleRecentList()
+		},
+	}
+
+	recentPinCmd := &cobra.Command{
+		Use:   "pin <id-or-name>",
+		Short: "Mark a recent target as a favorite so it stays at the top of the picker",
+		Args:  cobra.ExactArgs(1),
+		Run: func(cmd *cobra.Command, args []string) {
+			handleRecentPin(args[0], true)
+		},
+	}
+
+	recentUnpinCmd := &cobra.Command{
+		Use:   "unpin <id-or-name>",
+		Short: "Remove a target from the favorites",
+		Args:  cobra.ExactArgs(1),
+		Run: func(cmd *cobra.Command, args []string) {
+			handleRecentPin(args[0], false)
+		},
+	}
+	recentCmd.AddCommand(recentListCmd, recentPinCmd, recentUnpinCmd)
+
+	// Cache command
+	cacheCmd := &cobra.Command{
+		Use:   "cache",
+		Short: "Manage the local inventory cache",
+		Args:  cobra.NoArgs,
+	}
+
+	cacheClearCmd := &cobra.Command{
+		Use:   "clear",
+		Short: "Delete every cached EC2 and RDS listing",
+		Args:  cobra.NoArgs,
+		Run: func(cmd *cobra.Command, args []string) {
+			handleCacheClear()
+		},
+	}
+	cacheCmd.AddCommand(cacheClearCmd)
+
+	// Recordings command
+	recordingsCmd := &cobra.Command{
+		Use:   "recordings",
+		Short: "Play back recorded EC2 sessions",
+		Long: `Session recordings made with --record are asciicast v2 files under
+~/.aws-go-tools/recordings/<instance-id>/, which asciinema can also play.`,
+		Args: cobra.NoArgs,
+	}
+
+	var playSpeed float64
+	var playIdleLimit time.Duration
+	recordingsPlayCmd := &cobra.Command{
+		Use:   "play <file>",
+		Short: "Replay a recorded session in the terminal",
+		Args:  cobra.ExactArgs(1),
+		Run: func(cmd *cobra.Command, args []string) {
+			handleRecordingPlay(args[0], playSpeed, playIdleLimit)
+		},
+	}
+	recordingsPlayCmd.Flags().Float64Var(&playSpeed, "speed", 1, "Playback speed multiplier")
+	recordingsPlayCmd.Flags().DurationVar(&playIdleLimit, "idle-limit", 2*time.Second, "Shorten pauses longer than this (0 keeps them)")
+	recordingsCmd.AddCommand(recordingsPlayCmd)
+
+	// Sessions command
+	var sessionState string
+	var mineOnly bool
+	sessionsCmd := &cobra.Command{
+		Use:   "sessions",
+		Short: "List and terminate Session Manager sessions",
+		Args:  cobra.NoArgs,
+	}
+
+	sessionsListCmd := &cobra.Command{
+		Use:   "list",
+		Short: "List active or ended sessions and who owns them",
+		Long: `List Session Manager sessions with their owner, target and start time. Targets that are
+listed EC2 instances are shown with their Name tag.`,
+		Example: `  aws-go-tools sessions list --all-regions
+  aws-go-tools sessions list --state History --mine`,
+		Args: cobra.NoArgs,
+		Run: func(cmd *cobra.Command, args []string) {
+			state, err := parseSessionState(sessionState)
+			if err != nil {
+				log.Fatalf("Invalid --state: %v", err)
+			}
+			ctx := context.Background()
+			cfg := loadAWSConfig(ctx)
+			handleSessionsList(ctx, cfg, state, mineOnly)
+		},
+	}
+	sessionsListCmd.Flags().StringVar(&sessionState, "state", sessionStateActive, "Sessions to list: Active or History")
+
+	sessionsTerminateCmd := &cobra.Command{
+		Use:   "terminate [session-id]...",
+		Short: "Terminate sessions by ID, or pick them from the active sessions",
+		Long: `Terminate the given sessions. Without IDs, the active sessions are listed and any number
+of them can be picked to terminate.`,
+		Run: func(cmd *cobra.Command, args []string) {
+			ctx := context.Background()
+			cfg := loadAWSConfig(ctx)
+			handleSessionsTerminate(ctx, cfg, args, mineOnly)
+		},
+	}
+	sessionsCmd.PersistentFlags().BoolVar(&mineOnly, "mine", false, "Only include sessions started by the current AWS identity")
+	sessionsCmd.AddCommand(sessionsListCmd, sessionsTerminateCmd)
+
+	// Add command flags
+	filterUsage := "Filter instances, e.g. tag:Env=prod, instance-type=t3.*, vpc-id=vpc-123, state=running (repeatable)"
+	ec2Cmd.PersistentFlags().StringArrayVar(&ec2FilterExprs, "filter", nil, filterUsage)
+	rdsCmd.PersistentFlags().StringArrayVar(&rdsFilterExprs, "filter", nil, filterUsage)
+
+	outputUsage := "Output format: " + strings.Join(outputFormats, ", ")
+	ec2ListCmd.Flags().StringVarP(&outputFormat, "output", "o", outputTable, outputUsage)
+	rdsListCmd.Flags().StringVarP(&outputFormat, "output", "o", outputTable, outputUsage)
+	formatUsage := `Go template for each item, e.g. '{{.ID}}\t{{.PrivateIP}}\t{{tag "Env"}}' (helpers: tag, tags, default, join, upper, lower)`
+	for _, cmd := range []*cobra.Command{ec2ListCmd, rdsListCmd} {
+		cmd.Flags().StringVar(&formatTemplate, "format", "", formatUsage)
+		cmd.MarkFlagsMutuallyExclusive("output", "format")
+	}
+
+	for _, cmd := range []*cobra.Command{ec2Cmd, recentCmd} {
+		cmd.PersistentFlags().BoolVar(&recordSession, "record", appConfig.Recording.Enabled, "Record shell sessions under ~/.aws-go-tools/recordings")
+		cmd.PersistentFlags().BoolVar(&recordInput, "record-input", appConfig.Recording.Input, "Also record keystrokes with --record (may capture passwords)")
+	}
+
+	for _, cmd := range []*cobra.Command{ec2Cmd, ec2ConnectCmd, recentCmd} {
+		cmd.Flags().StringVar(&documentName, "document", "", "SSM session document for shell sessions (default: from config, else AWS-StartInteractiveCommand)")
+		cmd.Flags().StringArrayVar(&documentParams, "param", nil, "Session document parameter as key=value; values may use {{.Instance.*}} templates (repeatable)")
+		cmd.Flags().StringVar(&runAsUser, "run-as", "", "Start shell sessions as this OS user with sudo -iu (default: from config rules)")
+	}
+
+	for _, cmd := range []*cobra.Command{ec2Cmd, rdsTunnelCmd, recentCmd} {
+		cmd.PersistentFlags().BoolVar(&startStopped, "start", false, "Start the instance without asking if it is stopped")
+		cmd.PersistentFlags().DurationVar(&startTimeout, "start-timeout", 10*time.Minute, "How long to wait for a started instance and its SSM agent")
+	}
+
 	// Add persistent flags
-	rootCmd.PersistentFlags().StringVarP(&profile, "profile", "p", "", "AWS profile to use")
+	rootCmd.PersistentFlags().StringArrayVarP(&profiles, "profile", "p", nil, "AWS profile to use (repeatable to list several accounts)")
+	rootCmd.PersistentFlags().StringSliceVar(&profileList, "profiles", nil, "List instances in these profiles' accounts at once (comma-separated)")
 	rootCmd.PersistentFlags().StringVarP(&region, "region", "r", "", "AWS region (optional, uses default region if not specified)")
+	rootCmd.PersistentFlags().StringSliceVar(&regionList, "regions", nil, "List instances in these regions at once (comma-separated)")
+	rootCmd.PersistentFlags().BoolVar(&allRegions, "all-regions", false, "List instances in every enabled region")
+	rootCmd.MarkFlagsMutuallyExclusive("regions", "all-regions")
+	rootCmd.PersistentFlags().DurationVar(&cacheTTL, "cache-ttl", appConfig.Cache.TTL, "Use cached listings younger than this (0 disables the cache)")
+	rootCmd.PersistentFlags().BoolVar(&refreshCache, "refresh", false, "Ignore the inventory cache and list from AWS")
+	rootCmd.PersistentFlags().BoolVar(&offline, "offline", false, "List from the inventory cache only, however old")
+	rootCmd.MarkFlagsMutuallyExclusive("refresh", "offline")
+	rootCmd.PersistentFlags().BoolVar(&usePlugin, "plugin", false, "Run sessions with session-manager-plugin instead of the built-in client")
+	rootCmd.PersistentFlags().DurationVar(&maxSessionDuration, "max-duration", 0, "End SSM sessions after this long (0 for no limit)")
+	rootCmd.PersistentFlags().IntVar(&maxResults, "max-results", 0, "Stop listing after this many instances (0 for no limit)")
 
 	// Add commands
-	rootCmd.AddCommand(versionCmd, ec2Cmd, rdsCmd)
+	rootCmd.AddCommand(versionCmd, ec2Cmd, rdsCmd, sessionsCmd, recentCmd, cacheCmd, recordingsCmd)
 
 	if err := rootCmd.Execute(); err != nil {
 		fmt.Fprintln(os.Stderr, err)
@@ -137,19 +604,25 @@ func loadConfig() {
 		Windows: ShellConfig{
 			Shell: "powershell.exe",
 		},
+		Cache: CacheConfig{
+			TTL: 5 * time.Minute,
+		},
 	}
 
 	// Try to load config from file
 	configPaths := []string{
 		"config.yaml",
-		filepath.Join(os.Getenv("HOME"), ".aws-go-tools", "config.yaml"),
+		filepath.Join(appDataDir(), "config.yaml"),
 		"/etc/aws-go-tools/config.yaml",
 	}
 
 	for _, configPath := range configPaths {
 		if data, err := os.ReadFile(configPath); err == nil {
 			if err := yaml.Unmarshal(data, &appConfig); err == nil {
-				fmt.Printf("Loaded configuration from: %s\n", configPath)
+				fmt.Fprintf(os.Stderr, "Loaded configuration from: %s\n", configPath)
+				if err := validateRules(appConfig.Rules); err != nil {
+					fmt.Fprintf(os.Stderr, "Warning: %s: %v\n", configPath, err)
+				}
 				return
 			}
 		}
@@ -158,23 +631,41 @@ func loadConfig() {
 	// Using default configuration if no file found
 }
 
+// loadAWSConfig loads the configuration of every profile into accounts and
+// returns the first one's
 func loadAWSConfig(ctx context.Context) aws.Config {
-	configOptions := []func(*config.LoadOptions) error{}
-
-	if profile != "" {
-		configOptions = append(configOptions, config.WithSharedConfigProfile(profile))
+	names := awsProfiles()
+	if len(names) == 0 {
+		// Default credential chain (AWS_PROFILE, environment, instance role...)
+		names = []string{""}
 	}
 
-	if region != "" {
-		configOptions = append(configOptions, config.WithRegion(region))
+	accounts = nil
+	for _, name := range names {
+		configOptions := []func(*config.LoadOptions) error{}
+
+		if name != "" {
+			configOptions = append(configOptions, config.WithSharedConfigProfile(name))
+		}
+
+		if region != "" {
+			configOptions = append(configOptions, config.WithRegion(region))
+		}
+
+		cfg, err := config.LoadDefaultConfig(ctx, configOptions...)
+		if err != nil {
+			log.Fatalf("Failed to load AWS config for profile %q: %v", name, err)
+		}
+
+		accounts = append(accounts, awsAccount{Profile: name, Config: cfg})
 	}
 
-	cfg, err := config.LoadDefaultConfig(ctx, configOptions...)
-	if err != nil {
-		log.Fatalf("Failed to load AWS config: %v", err)
+	// Offline listings take the account IDs from the cache
+	if multiAccount() && !offline {
+		lookupAccountIDs(ctx, accounts)
 	}
 
-	return cfg
+	return accounts[0].Config
 }
 
 func handleEC2Mode(ctx context.Context, cfg aws.Config) {
@@ -195,10 +686,31 @@ func handleEC2Mode(ctx context.Context, cfg aws.Config) {
 		log.Fatalf("Failed to select instance: %v", err)
 	}
 
+	rememberInstance(selectedInstance)
+
 	// Connect via SSM
 	err = connectToInstance(ctx, cfg, selectedInstance)
 	if err != nil {
-		log.Fatalf("Failed to connect to instance: %v", err)
+		fatalSession("Failed to connect to instance: %v", err)
+	}
+}
+
+func handleEC2Connect(ctx context.Context, cfg aws.Config, target string) {
+	instances, err := listInstances(ctx, cfg)
+	if err != nil {
+		log.Fatalf("Failed to list instances: %v", err)
+	}
+
+	selectedInstance, err := resolveInstance(instances, target)
+	if err != nil {
+		log.Fatalf("Failed to resolve target: %v", err)
+	}
+
+	rememberInstance(selectedInstance)
+
+	err = connectToInstance(ctx, cfg, selectedInstance)
+	if err != nil {
+		fatalSession("Failed to connect to instance: %v", err)
 	}
 }
 
@@ -221,11 +733,13 @@ func handleRDSMode(ctx context.Context, cfg aws.Config) {
 	}
 
 	// Prompt for username
-	username, err := promptForUsername()
+	username, err := promptForUsername(selectedRDS)
 	if err != nil {
 		log.Fatalf("Failed to get username: %v", err)
 	}
 
+	rememberRDS(selectedRDS, username)
+
 	// Generate IAM auth token
 	err = generateRDSAuthToken(ctx, cfg, selectedRDS, username)
 	if err != nil {
@@ -233,302 +747,760 @@ func handleRDSMode(ctx context.Context, cfg aws.Config) {
 	}
 }
 
+// listInstances lists EC2 instances in every target region of every account
 func listInstances(ctx context.Context, cfg aws.Config) ([]Instance, error) {
+	return listAcrossAccounts(ctx, cfg, "EC2 instances", cacheKindEC2, ec2FilterExprs, func(ctx context.Context, acct awsAccount) ([]Instance, error) {
+		return cachedList(ctx, cacheKindEC2, acct, ec2FilterExprs, func(ctx context.Context, cfg aws.Config) ([]Instance, error) {
+			instances, err := listRegionInstances(ctx, cfg)
+			for i := range instances {
+				instances[i].Profile = acct.Profile
+				instances[i].AccountID = acct.AccountID
+			}
+			return instances, err
+		})
+	})
+}
+
+// ec2ExtraFilters are passed to DescribeInstances as they are, alongside the
+// --filter expressions. They aren't part of the cache key, so callers setting
+// them must not use the cache.
+var ec2ExtraFilters []types.Filter
+
+// listRegionInstances lists EC2 instances in cfg's region
+func listRegionInstances(ctx context.Context, cfg aws.Config) ([]Instance, error) {
 	ec2Client := ec2.NewFromConfig(cfg)
 
-	// Describe all instances
-	result, err := ec2Client.DescribeInstances(ctx, &ec2.DescribeInstancesInput{})
+	filters, err := parseFilters(ec2FilterExprs, ec2FilterAliases)
 	if err != nil {
-		return nil, fmt.Errorf("failed to describe instances: %w", err)
+		return nil, err
+	}
+
+	// Terminated instances are filtered out server-side rather than fetched and dropped
+	if !hasFilter(filters, "instance-state-name") {
+		filters = append(filters, instanceFilter{
+			Name:   "instance-state-name",
+			Values: []string{"pending", "running", "shutting-down", "stopping", "stopped"},
+		})
+	}
+
+	// Every EC2 filter can be evaluated server-side
+	input := &ec2.DescribeInstancesInput{}
+	for _, f := range filters {
+		input.Filters = append(input.Filters, types.Filter{
+			Name:   aws.String(f.Name),
+			Values: f.Values,
+		})
 	}
+	input.Filters = append(input.Filters, ec2ExtraFilters...)
 
 	var instances []Instance
 
-	for _, reservation := range result.Reservations {
-		for _, instance := range reservation.Instances {
-			// Skip terminated instances
-			if instance.State.Name == types.InstanceStateNameTerminated {
-				continue
+	paginator := ec2.NewDescribeInstancesPaginator(ec2Client, input)
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("failed to describe instances: %w", err)
+		}
+
+		for _, reservation := range page.Reservations {
+			for _, instance := range reservation.Instances {
+				inst := newInstance(instance)
+				inst.Region = cfg.Region
+				instances = append(instances, inst)
 			}
+		}
 
-			inst := Instance{
-				ID:           aws.ToString(instance.InstanceId),
-				InstanceType: string(instance.InstanceType),
-				State:        string(instance.State.Name),
+		if maxResults > 0 && len(instances) >= maxResults {
+			var truncated bool
+			instances, truncated = truncateResults(instances, paginator.HasMorePages())
+			if truncated {
+				warnTruncated("EC2 instances")
 			}
+			break
+		}
+	}
 
-			// Determine platform (defaults to Linux if not specified)
-			inst.Platform = "linux"
-			if instance.Platform != "" {
-				platform := strings.ToLower(string(instance.Platform))
-				if platform == "windows" {
-					inst.Platform = "windows"
-				}
+	// SSM status is best-effort so listing still works without ssm:DescribeInstanceInformation
+	if err := addSSMStatus(ctx, cfg, instances); err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: could not determine SSM agent status: %v\n", err)
+	}
+
+	return instances, nil
+}
+
+// newInstance converts an EC2 API instance into an Instance
+func newInstance(instance types.Instance) Instance {
+	inst := Instance{
+		ID:           aws.ToString(instance.InstanceId),
+		InstanceType: string(instance.InstanceType),
+		VpcID:        aws.ToString(instance.VpcId),
+		LaunchTime:   aws.ToTime(instance.LaunchTime),
+		Tags:         make(map[string]string, len(instance.Tags)),
+
+		ImageID:         aws.ToString(instance.ImageId),
+		PlatformDetails: aws.ToString(instance.PlatformDetails),
+	}
+	if instance.State != nil {
+		inst.State = string(instance.State.Name)
+	}
+	if instance.Placement != nil {
+		inst.AvailabilityZone = aws.ToString(instance.Placement.AvailabilityZone)
+	}
+
+	// Determine platform (defaults to Linux if not specified)
+	inst.Platform = "linux"
+	if instance.Platform != "" {
+		platform := strings.ToLower(string(instance.Platform))
+		if platform == "windows" {
+			inst.Platform = "windows"
+		}
+	}
+	// Also check PlatformDetails for more accurate detection
+	if instance.PlatformDetails != nil {
+		platformDetails := strings.ToLower(aws.ToString(instance.PlatformDetails))
+		if strings.Contains(platformDetails, "windows") {
+			inst.Platform = "windows"
+		}
+	}
+
+	// Get instance name and tags
+	for _, tag := range instance.Tags {
+		inst.Tags[aws.ToString(tag.Key)] = aws.ToString(tag.Value)
+	}
+	inst.Name = inst.Tags["Name"]
+
+	// Get IP addresses
+	if instance.PrivateIpAddress != nil {
+		inst.PrivateIP = aws.ToString(instance.PrivateIpAddress)
+	}
+	if instance.PublicIpAddress != nil {
+		inst.PublicIP = aws.ToString(instance.PublicIpAddress)
+	}
+
+	return inst
+}
+
+// addSSMStatus joins instances with the agent details reported by Systems
+// Manager. Instances Systems Manager doesn't know about are marked NotManaged.
+func addSSMStatus(ctx context.Context, cfg aws.Config, instances []Instance) error {
+	ssmClient := ssm.NewFromConfig(cfg)
+
+	// The InstanceIds filter accepts a limited number of values per request
+	const batchSize = 50
+
+	info := make(map[string]ssmtypes.InstanceInformation)
+	for start := 0; start < len(instances); start += batchSize {
+		var ids []string
+		for _, inst := range instances[start:min(start+batchSize, len(instances))] {
+			ids = append(ids, inst.ID)
+		}
+
+		paginator := ssm.NewDescribeInstanceInformationPaginator(ssmClient, &ssm.DescribeInstanceInformationInput{
+			Filters: []ssmtypes.InstanceInformationStringFilter{
+				{Key: aws.String("InstanceIds"), Values: ids},
+			},
+		})
+		for paginator.HasMorePages() {
+			page, err := paginator.NextPage(ctx)
+			if err != nil {
+				return fmt.Errorf("failed to describe SSM instance information: %w", err)
 			}
-			// Also check PlatformDetails for more accurate detection
-			if instance.PlatformDetails != nil {
-				platformDetails := strings.ToLower(aws.ToString(instance.PlatformDetails))
-				if strings.Contains(platformDetails, "windows") {
-					inst.Platform = "windows"
-				}
+			for _, item := range page.InstanceInformationList {
+				info[aws.ToString(item.InstanceId)] = item
 			}
+		}
+	}
 
-			// Get instance name from tags
-			for _, tag := range instance.Tags {
-				if aws.ToString(tag.Key) == "Name" {
-					inst.Name = aws.ToString(tag.Value)
-					break
-				}
+	for i := range instances {
+		item, ok := info[instances[i].ID]
+		if !ok {
+			instances[i].PingStatus = pingStatusNotManaged
+			continue
+		}
+		instances[i].PingStatus = string(item.PingStatus)
+		instances[i].AgentVersion = aws.ToString(item.AgentVersion)
+		instances[i].PlatformName = aws.ToString(item.PlatformName)
+		instances[i].LastPingTime = aws.ToTime(item.LastPingDateTime)
+	}
+
+	return nil
+}
+
+// refreshInstances re-describes instances and their SSM agent status, so
+// starting sessions or commands doesn't rely on a cached listing. The
+// instances are returned in the same order, except those that no longer
+// exist, which are returned separately as gone.
+func refreshInstances(ctx context.Context, cfg aws.Config, instances []Instance) (current, gone []Instance, err error) {
+	// The instance-id filter accepts a limited number of values per request,
+	// and unlike InstanceIds doesn't fail on instances that have vanished
+	const batchSize = 200
+
+	type location struct{ Profile, Region string }
+	groups := make(map[location][]int)
+	for i, inst := range instances {
+		loc := location{Profile: inst.Profile, Region: inst.Region}
+		groups[loc] = append(groups[loc], i)
+	}
+
+	described := make(map[location]map[string]Instance)
+	for loc, indexes := range groups {
+		regionCfg := accountConfig(cfg, loc.Profile, loc.Region)
+		ec2Client := ec2.NewFromConfig(regionCfg)
+		found := make(map[string]Instance)
+
+		for start := 0; start < len(indexes); start += batchSize {
+			var ids []string
+			for _, i := range indexes[start:min(start+batchSize, len(indexes))] {
+				ids = append(ids, instances[i].ID)
 			}
 
-			// Get IP addresses
-			if instance.PrivateIpAddress != nil {
-				inst.PrivateIP = aws.ToString(instance.PrivateIpAddress)
+			paginator := ec2.NewDescribeInstancesPaginator(ec2Client, &ec2.DescribeInstancesInput{
+				Filters: []types.Filter{{Name: aws.String("instance-id"), Values: ids}},
+			})
+			for paginator.HasMorePages() {
+				page, err := paginator.NextPage(ctx)
+				if err != nil {
+					return nil, nil, fmt.Errorf("failed to describe instances: %w", err)
+				}
+				for _, reservation := range page.Reservations {
+					for _, instance := range reservation.Instances {
+						inst := newInstance(instance)
+						found[inst.ID] = inst
+					}
+				}
 			}
-			if instance.PublicIpAddress != nil {
-				inst.PublicIP = aws.ToString(instance.PublicIpAddress)
+		}
+
+		var group []Instance
+		for _, i := range indexes {
+			inst, ok := found[instances[i].ID]
+			if !ok {
+				continue
 			}
+			listed := instances[i]
+			inst.Profile = listed.Profile
+			inst.AccountID = listed.AccountID
+			inst.Region = listed.Region
+
+			// The listed agent status is kept if it can't be checked again
+			inst.PingStatus = listed.PingStatus
+			inst.AgentVersion = listed.AgentVersion
+			inst.PlatformName = listed.PlatformName
+			inst.LastPingTime = listed.LastPingTime
+			group = append(group, inst)
+		}
 
-			instances = append(instances, inst)
+		if err := addSSMStatus(ctx, regionCfg, group); err != nil {
+			fmt.Fprintf(os.Stderr, "Warning: could not refresh SSM agent status: %v\n", err)
 		}
+		for _, inst := range group {
+			found[inst.ID] = inst
+		}
+		described[loc] = found
 	}
 
-	return instances, nil
+	for _, inst := range instances {
+		refreshed, ok := described[location{Profile: inst.Profile, Region: inst.Region}][inst.ID]
+		if !ok {
+			gone = append(gone, inst)
+			continue
+		}
+		current = append(current, refreshed)
+	}
+	return current, gone, nil
 }
 
-func displayInstances(instances []Instance) {
-	fmt.Println("\nAvailable EC2 Instances:")
-	fmt.Println(strings.Repeat("=", 120))
+// refreshInstance re-describes one instance, see refreshInstances
+func refreshInstance(ctx context.Context, cfg aws.Config, instance Instance) (Instance, error) {
+	current, _, err := refreshInstances(ctx, cfg, []Instance{instance})
+	if err != nil {
+		return instance, err
+	}
+	if len(current) == 0 {
+		return instance, fmt.Errorf("instance %s no longer exists", instance.ID)
+	}
+	return current[0], nil
+}
 
-	w := tabwriter.NewWriter(os.Stdout, 0, 0, 3, ' ', 0)
-	fmt.Fprintln(w, "INSTANCE ID\tNAME\tSTATE\tTYPE\tPRIVATE IP\tPUBLIC IP")
-	fmt.Fprintln(w, strings.Repeat("-", 19)+"\t"+strings.Repeat("-", 30)+"\t"+
-		strings.Repeat("-", 10)+"\t"+strings.Repeat("-", 12)+"\t"+strings.Repeat("-", 15)+"\t"+strings.Repeat("-", 15))
+// truncateResults trims items to --max-results and reports whether that left
+// any out: items beyond the cap, or more pages when more is set. Exactly
+// --max-results items with nothing after them are complete.
+func truncateResults[T any](items []T, more bool) ([]T, bool) {
+	if maxResults <= 0 || len(items) < maxResults {
+		return items, false
+	}
+	return items[:maxResults], len(items) > maxResults || more
+}
+
+// warnTruncated tells the user that a listing stopped at --max-results
+func warnTruncated(what string) {
+	fmt.Fprintf(os.Stderr, "Warning: stopped after %d %s (--max-results); results may be incomplete\n", maxResults, what)
+}
+
+// displayInstances writes instances as a table. The wide table adds the
+// platform, VPC, availability zone, SSM agent status and launch time.
+func displayInstances(out io.Writer, instances []Instance, wide bool) {
+	w := tabwriter.NewWriter(out, 0, 0, 3, ' ', 0)
+	header := "INSTANCE ID\tNAME\tSTATE\tTYPE\tPRIVATE IP\tPUBLIC IP"
+	if wide {
+		header += "\tPLATFORM\tVPC\tAZ\tSSM\tLAUNCHED"
+	}
+	fmt.Fprintln(w, header+locationColumns("ACCOUNT", "", "REGION"))
 
 	for _, inst := range instances {
-		name := inst.Name
-		if name == "" {
-			name = "-"
-		}
-		publicIP := inst.PublicIP
-		if publicIP == "" {
-			publicIP = "-"
+		row := []string{inst.ID, orDash(inst.Name), inst.State, inst.InstanceType, orDash(inst.PrivateIP), orDash(inst.PublicIP)}
+		if wide {
+			launched := "-"
+			if !inst.LaunchTime.IsZero() {
+				launched = inst.LaunchTime.Local().Format("2006-01-02 15:04")
+			}
+			row = append(row, orDash(inst.Platform), orDash(inst.VpcID), orDash(inst.AvailabilityZone), orDash(inst.PingStatus), launched)
 		}
-
-		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\n",
-			inst.ID, name, inst.State, inst.InstanceType, inst.PrivateIP, publicIP)
+		fmt.Fprintln(w, strings.Join(row, "\t")+locationColumns(inst.Profile, inst.AccountID, inst.Region))
 	}
 	w.Flush()
-	fmt.Println(strings.Repeat("=", 120))
 }
 
+// selectInstance asks the user to pick an instance, listing favorites and
+// recently used instances first
 func selectInstance(instances []Instance) (Instance, error) {
-	var options []string
-	for _, inst := range instances {
-		options = append(options, fmt.Sprintf("%s (%s) - %s", inst.Name, inst.ID, inst.State))
+	h := loadHistory()
+	lookup := func(i int) (historyEntry, bool) {
+		return h.lookup(historyKindEC2, instances[i].Profile, instances[i].Region, instances[i].ID)
 	}
-
-	var selected string
-	prompt := &survey.Select{
-		Message:  "Select an EC2 instance to connect:",
-		Options:  options,
-		PageSize: 10,
+	order := pickerOrder(len(instances), lookup)
+
+	var options, search []string
+	for _, i := range order {
+		inst := instances[i]
+		option := fmt.Sprintf("%s (%s) - %s", inst.Name, inst.ID, inst.State)
+		if location := locationLabel(inst.Profile, inst.Region); location != "" {
+			option = fmt.Sprintf("%s (%s, %s) - %s", inst.Name, inst.ID, location, inst.State)
+		}
+		if !inst.ssmReachable() {
+			option += fmt.Sprintf(" [SSM: %s]", inst.PingStatus)
+		}
+		options = append(options, historyMark(lookup(i))+option)
+		search = append(search, instanceSearchText(inst))
 	}
 
-	err := survey.AskOne(prompt, &selected)
+	index, err := fuzzySelect("Select an EC2 instance to connect:", options, search, func(i int) string {
+		return instancePreview(instances[order[i]])
+	})
 	if err != nil {
 		return Instance{}, err
 	}
 
-	// Find the selected instance by matching the formatted string
-	for i, opt := range options {
-		if opt == selected {
-			return instances[i], nil
+	return instances[order[index]], nil
+}
+
+func connectToInstance(ctx context.Context, cfg aws.Config, instance Instance) error {
+	// The session must use the instance's own account and region
+	cfg = accountConfig(cfg, instance.Profile, instance.Region)
+
+	instance, err := refreshInstance(ctx, cfg, instance)
+	if err != nil {
+		return err
+	}
+
+	instance, err = ensureInstanceRunning(ctx, cfg, instance)
+	if err != nil {
+		return err
+	}
+
+	if err := checkSessionTarget(instance); err != nil {
+		return err
+	}
+
+	// Pick the shell and document from the platform defaults and rules
+	if rulesMatchAccounts(appConfig.Rules) {
+		fillAccountID(ctx, cfg, &instance)
+	}
+	shell := shellConfigFor(appConfig, instance)
+	if runAsUser != "" {
+		shell.RunAs = runAsUser
+	}
+
+	doc, err := shellDocument(shell, documentName, documentParams)
+	if err != nil {
+		return err
+	}
+	if len(shell.Shells) > 0 && doc.uses("Shell", "Command") {
+		shell.Shell = detectShell(ctx, cfg, instance, shell.Shells, shell.Shell)
+	}
+	if runAsUser != "" && !doc.uses("RunAs", "Command") {
+		return fmt.Errorf("document %s does not use {{.RunAs}} or {{.Command}}, so --run-as has no effect", doc.Name)
+	}
+	if shell.RunAs != "" && doc.uses("RunAs", "Command") {
+		if err := checkRunAsUser(ctx, cfg, instance, shell.RunAs); err != nil {
+			return err
 		}
 	}
+	parameters, err := doc.render(instance, shell)
+	if err != nil {
+		return err
+	}
+
+	// Start SSM session
+	switch {
+	case doc.Name == defaultSessionDocument && shell.RunAs != "":
+		fmt.Printf("\nStarting SSM session to %s (%s) with %s shell as %s...\n", instance.Name, instance.ID, shell.Shell, shell.RunAs)
+	case doc.Name == defaultSessionDocument:
+		fmt.Printf("\nStarting SSM session to %s (%s) with %s shell...\n", instance.Name, instance.ID, shell.Shell)
+	default:
+		fmt.Printf("\nStarting SSM session to %s (%s) with document %s...\n", instance.Name, instance.ID, doc.Name)
+	}
 
-	return Instance{}, fmt.Errorf("selected instance not found")
+	startSessionInput := &ssm.StartSessionInput{
+		Target:       aws.String(instance.ID),
+		DocumentName: aws.String(doc.Name),
+		Parameters:   parameters,
+	}
+
+	var rec *sessionRecorder
+	if recordSession {
+		var path string
+		if rec, path, err = startRecording(instance, recordInput); err != nil {
+			return fmt.Errorf("failed to start recording: %w", err)
+		}
+		defer func() {
+			if err := rec.Close(); err != nil {
+				fmt.Fprintf(os.Stderr, "Warning: recording %s may be incomplete: %v\n", path, err)
+			}
+		}()
+		fmt.Fprintf(os.Stderr, "Recording session to %s\n", path)
+	}
+
+	return runSession(ctx, cfg, startSessionInput, "Connected! Type 'exit' to close the session.", rec)
 }
 
-func connectToInstance(ctx context.Context, cfg aws.Config, instance Instance) error {
+// checkSessionTarget verifies that an instance can accept an SSM session
+func checkSessionTarget(instance Instance) error {
 	// Check if instance is running
 	if instance.State != string(types.InstanceStateNameRunning) {
 		return fmt.Errorf("instance %s is not in running state (current state: %s)", instance.ID, instance.State)
 	}
 
+	// Check that the SSM agent can accept a session
+	if !instance.ssmReachable() {
+		return fmt.Errorf("instance %s is not reachable through SSM (agent status: %s). Check that the SSM agent is running and the instance profile allows Systems Manager", instance.ID, instance.PingStatus)
+	}
+
+	return nil
+}
+
+// runSession starts an SSM session and stays attached to it until it ends,
+// using the built-in client or, with --plugin, session-manager-plugin. Port
+// sessions on a local port, and sessions that require KMS encryption, use the
+// plugin when it is installed.
+// readyMessage is printed once the session has been started; an empty
+// readyMessage keeps runSession quiet. Status messages go to stderr so stdout
+// carries nothing but session data (e.g. for ssh-proxy). The session is
+// recorded to rec unless it is nil.
+//
+// A shell that exits unsuccessfully is reported as a sessionExitError.
+// However the session ends, including by a signal or --max-duration, it is
+// terminated so it doesn't stay Connected.
+func runSession(ctx context.Context, cfg aws.Config, input *ssm.StartSessionInput, readyMessage string, rec *sessionRecorder) error {
 	// Check if session-manager-plugin is installed
-	if _, err := exec.LookPath("session-manager-plugin"); err != nil {
-		return fmt.Errorf("session-manager-plugin not found. Please install it from: https://docs.aws.amazon.com/systems-manager/latest/userguide/session-manager-working-with-install-plugin.html")
+	if usePlugin && !pluginInstalled() {
+		return fmt.Errorf("session-manager-plugin not found. Please install it from: %s", pluginInstallURL)
 	}
 
+	ctx, stop := sessionContext(ctx, maxSessionDuration)
+	defer stop()
+
 	ssmClient := ssm.NewFromConfig(cfg)
 
-	// Determine which shell to use based on platform
-	var shellCommand string
-	if instance.Platform == "windows" {
-		shellCommand = appConfig.Windows.Shell
-	} else {
-		shellCommand = appConfig.Linux.Shell
+	// The built-in client carries one connection at a time over a port
+	// session, while the plugin multiplexes them
+	plugin := usePlugin
+	if !plugin && len(input.Parameters["localPortNumber"]) > 0 {
+		if pluginInstalled() && (rec == nil || pluginRecordingSupported) {
+			plugin = true
+		} else {
+			fmt.Fprintf(os.Stderr, "Warning: without session-manager-plugin only one connection at a time is forwarded; install it from: %s\n", pluginInstallURL)
+		}
 	}
 
-	// Start SSM session
-	fmt.Printf("\nStarting SSM session to %s (%s) with %s shell...\n", instance.Name, instance.ID, shellCommand)
+	err := attachSession(ctx, cfg, ssmClient, input, readyMessage, rec, plugin)
+
+	// The built-in client refuses KMS-encrypted sessions before anything is
+	// attached, so the session can be started again with the plugin
+	if errors.Is(err, errKMSEncryption) && ctx.Err() == nil {
+		switch {
+		case !pluginInstalled():
+			err = fmt.Errorf("%w; install session-manager-plugin to connect: %s", err, pluginInstallURL)
+		case rec != nil && !pluginRecordingSupported:
+			err = fmt.Errorf("%w, and sessions run with session-manager-plugin can't be recorded on this platform", err)
+		default:
+			fmt.Fprintln(os.Stderr, "The session requires KMS encryption; starting it again with session-manager-plugin...")
+			err = attachSession(ctx, cfg, ssmClient, input, readyMessage, rec, true)
+		}
+	}
 
-	startSessionInput := &ssm.StartSessionInput{
-		Target:       aws.String(instance.ID),
-		DocumentName: aws.String("AWS-StartInteractiveCommand"),
-		Parameters: map[string][]string{
-			"command": {shellCommand},
-		},
+	switch cause := context.Cause(ctx); {
+	case errors.Is(cause, errMaxDuration):
+		fmt.Fprintf(os.Stderr, "\nEnding the session after --max-duration of %s.\n", maxSessionDuration)
+		return nil
+	case errors.Is(cause, errInterrupted):
+		if readyMessage != "" {
+			fmt.Fprintln(os.Stderr, "\nSession terminated.")
+		}
+		return nil
+	}
+	var exitErr *sessionExitError
+	if err != nil && !(errors.As(err, &exitErr) && exitErr.err == nil) {
+		return err
 	}
 
-	result, err := ssmClient.StartSession(ctx, startSessionInput)
+	if readyMessage != "" {
+		fmt.Fprintln(os.Stderr, "\nSession ended.")
+	}
+	return err
+}
+
+// attachSession starts a session and runs it with session-manager-plugin when
+// plugin is set, or the built-in client otherwise. The session is terminated
+// when it ends.
+func attachSession(ctx context.Context, cfg aws.Config, ssmClient *ssm.Client, input *ssm.StartSessionInput, readyMessage string, rec *sessionRecorder, plugin bool) error {
+	result, err := ssmClient.StartSession(ctx, input)
 	if err != nil {
 		return fmt.Errorf("failed to start session: %w", err)
 	}
+	defer terminateSession(ctx, ssmClient, aws.ToString(result.SessionId))
 
-	// Build the session-manager-plugin command
-	sessionID := aws.ToString(result.SessionId)
-	streamURL := aws.ToString(result.StreamUrl)
-	tokenValue := aws.ToString(result.TokenValue)
-
-	// Get the region from config
-	region := cfg.Region
+	if plugin {
+		return runPluginSession(ctx, cfg, result, input, readyMessage, rec)
+	}
+	return runNativeSession(ctx, result, input, readyMessage, rec)
+}
 
+// runPluginSession hands a started session to session-manager-plugin, which
+// is stopped when ctx is done
+func runPluginSession(ctx context.Context, cfg aws.Config, result *ssm.StartSessionOutput, input *ssm.StartSessionInput, readyMessage string, rec *sessionRecorder) error {
 	// Prepare the session data JSON using proper marshaling
 	sessionDataStruct := SessionData{
-		SessionId:  sessionID,
-		StreamUrl:  streamURL,
-		TokenValue: tokenValue,
+		SessionId:  aws.ToString(result.SessionId),
+		StreamUrl:  aws.ToString(result.StreamUrl),
+		TokenValue: aws.ToString(result.TokenValue),
 	}
 
 	sessionDataBytes, err := json.Marshal(sessionDataStruct)
 	if err != nil {
 		return fmt.Errorf("failed to marshal session data: %w", err)
 	}
-	sessionData := string(sessionDataBytes)
 
-	// Execute session-manager-plugin
-	cmd := exec.Command(
+	// The plugin reads the session parameters, such as localPortNumber, from
+	// the StartSession input
+	inputBytes, err := json.Marshal(input)
+	if err != nil {
+		return fmt.Errorf("failed to marshal session input: %w", err)
+	}
+
+	cmd := exec.CommandContext(ctx,
 		"session-manager-plugin",
-		sessionData,
-		region,
+		string(sessionDataBytes),
+		cfg.Region,
 		"StartSession",
+		"",
+		string(inputBytes),
+		fmt.Sprintf("https://ssm.%s.amazonaws.com", cfg.Region),
 	)
+	cmd.Cancel = func() error { return stopPlugin(cmd) }
+	cmd.WaitDelay = pluginStopDelay
+
+	// The plugin puts the terminal in raw mode, and can't restore it if it
+	// is killed
+	if fd := int(os.Stdin.Fd()); term.IsTerminal(fd) {
+		if state, err := term.GetState(fd); err == nil {
+			defer term.Restore(fd, state)
+		}
+	}
 
-	cmd.Stdin = os.Stdin
-	cmd.Stdout = os.Stdout
-	cmd.Stderr = os.Stderr
-
-	fmt.Println("Connected! Type 'exit' to close the session.")
-
-	if err := cmd.Run(); err != nil {
-		return fmt.Errorf("session-manager-plugin error: %w", err)
+	if readyMessage != "" {
+		fmt.Fprintln(os.Stderr, readyMessage)
 	}
 
-	fmt.Println("\nSession ended.")
+	if rec != nil {
+		err = runRecorded(cmd, rec)
+	} else {
+		cmd.Stdin = os.Stdin
+		cmd.Stdout = os.Stdout
+		cmd.Stderr = os.Stderr
+		err = cmd.Run()
+	}
+	if err != nil {
+		return pluginError(err)
+	}
 	return nil
 }
 
 // RDS-related functions
 
+// listRDSInstances lists RDS instances in every target region of every account
 func listRDSInstances(ctx context.Context, cfg aws.Config) ([]RDSInstance, error) {
+	return listAcrossAccounts(ctx, cfg, "RDS instances", cacheKindRDS, rdsFilterExprs, func(ctx context.Context, acct awsAccount) ([]RDSInstance, error) {
+		return cachedList(ctx, cacheKindRDS, acct, rdsFilterExprs, func(ctx context.Context, cfg aws.Config) ([]RDSInstance, error) {
+			instances, err := listRegionRDSInstances(ctx, cfg)
+			for i := range instances {
+				instances[i].Profile = acct.Profile
+				instances[i].AccountID = acct.AccountID
+			}
+			return instances, err
+		})
+	})
+}
+
+// listRegionRDSInstances lists RDS instances in cfg's region
+func listRegionRDSInstances(ctx context.Context, cfg aws.Config) ([]RDSInstance, error) {
 	rdsClient := rds.NewFromConfig(cfg)
 
-	// Describe all RDS instances
-	result, err := rdsClient.DescribeDBInstances(ctx, &rds.DescribeDBInstancesInput{})
+	filters, err := parseFilters(rdsFilterExprs, rdsFilterAliases)
+	if err != nil {
+		return nil, err
+	}
+	serverFilters, clientFilters, err := splitRDSFilters(filters)
 	if err != nil {
-		return nil, fmt.Errorf("failed to describe RDS instances: %w", err)
+		return nil, err
 	}
 
-	var instances []RDSInstance
+	input := &rds.DescribeDBInstancesInput{}
+	for _, f := range serverFilters {
+		input.Filters = append(input.Filters, rdstypes.Filter{
+			Name:   aws.String(f.Name),
+			Values: f.Values,
+		})
+	}
 
-	for _, dbInstance := range result.DBInstances {
-		engine := aws.ToString(dbInstance.Engine)
+	var instances []RDSInstance
 
-		// Skip Oracle databases as they don't support IAM authentication
-		if strings.Contains(strings.ToLower(engine), "oracle") {
-			continue
+	paginator := rds.NewDescribeDBInstancesPaginator(rdsClient, input)
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("failed to describe RDS instances: %w", err)
 		}
 
-		inst := RDSInstance{
-			Identifier: aws.ToString(dbInstance.DBInstanceIdentifier),
-			Engine:     engine,
-			Status:     aws.ToString(dbInstance.DBInstanceStatus),
-		}
+		for _, dbInstance := range page.DBInstances {
+			engine := aws.ToString(dbInstance.Engine)
 
-		if dbInstance.Endpoint != nil {
-			inst.Endpoint = aws.ToString(dbInstance.Endpoint.Address)
-			if dbInstance.Endpoint.Port != nil {
-				inst.Port = *dbInstance.Endpoint.Port
+			// Skip Oracle databases as they don't support IAM authentication
+			if strings.Contains(strings.ToLower(engine), "oracle") {
+				continue
 			}
+
+			inst := RDSInstance{
+				Identifier:    aws.ToString(dbInstance.DBInstanceIdentifier),
+				Region:        cfg.Region,
+				Engine:        engine,
+				EngineVersion: aws.ToString(dbInstance.EngineVersion),
+				Status:        aws.ToString(dbInstance.DBInstanceStatus),
+				InstanceClass: aws.ToString(dbInstance.DBInstanceClass),
+				Tags:          make(map[string]string, len(dbInstance.TagList)),
+
+				AvailabilityZone: aws.ToString(dbInstance.AvailabilityZone),
+			}
+
+			if dbInstance.Endpoint != nil {
+				inst.Endpoint = aws.ToString(dbInstance.Endpoint.Address)
+				if dbInstance.Endpoint.Port != nil {
+					inst.Port = *dbInstance.Endpoint.Port
+				}
+			}
+			if dbInstance.DBSubnetGroup != nil {
+				inst.VpcID = aws.ToString(dbInstance.DBSubnetGroup.VpcId)
+			}
+			for _, tag := range dbInstance.TagList {
+				inst.Tags[aws.ToString(tag.Key)] = aws.ToString(tag.Value)
+			}
+
+			// Tags and patterns can't be filtered by the RDS API
+			if !matchRDSFilters(inst, clientFilters) {
+				continue
+			}
+
+			instances = append(instances, inst)
 		}
 
-		instances = append(instances, inst)
+		if maxResults > 0 && len(instances) >= maxResults {
+			var truncated bool
+			instances, truncated = truncateResults(instances, paginator.HasMorePages())
+			if truncated {
+				warnTruncated("RDS instances")
+			}
+			break
+		}
 	}
 
 	return instances, nil
 }
 
-func displayRDSInstances(instances []RDSInstance) {
-	fmt.Println("\nAvailable RDS Instances:")
-	fmt.Println(strings.Repeat("=", 120))
-
-	w := tabwriter.NewWriter(os.Stdout, 0, 0, 3, ' ', 0)
-	fmt.Fprintln(w, "IDENTIFIER\tENGINE\tSTATUS\tENDPOINT\tPORT")
-	fmt.Fprintln(w, strings.Repeat("-", 40)+"\t"+
-		strings.Repeat("-", 15)+"\t"+strings.Repeat("-", 15)+"\t"+strings.Repeat("-", 50)+"\t"+strings.Repeat("-", 6))
+// displayRDSInstances writes RDS instances as a table. The wide table adds
+// the engine version, class, VPC and availability zone.
+func displayRDSInstances(out io.Writer, instances []RDSInstance, wide bool) {
+	w := tabwriter.NewWriter(out, 0, 0, 3, ' ', 0)
+	header := "IDENTIFIER\tENGINE\tSTATUS\tENDPOINT\tPORT"
+	if wide {
+		header += "\tVERSION\tCLASS\tVPC\tAZ"
+	}
+	fmt.Fprintln(w, header+locationColumns("ACCOUNT", "", "REGION"))
 
 	for _, inst := range instances {
-		endpoint := inst.Endpoint
-		if endpoint == "" {
-			endpoint = "-"
-		}
-		port := fmt.Sprintf("%d", inst.Port)
-		if inst.Port == 0 {
-			port = "-"
+		port := "-"
+		if inst.Port != 0 {
+			port = strconv.Itoa(int(inst.Port))
 		}
 
-		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\n",
-			inst.Identifier, inst.Engine, inst.Status, endpoint, port)
+		row := []string{inst.Identifier, inst.Engine, inst.Status, orDash(inst.Endpoint), port}
+		if wide {
+			row = append(row, orDash(inst.EngineVersion), orDash(inst.InstanceClass), orDash(inst.VpcID), orDash(inst.AvailabilityZone))
+		}
+		fmt.Fprintln(w, strings.Join(row, "\t")+locationColumns(inst.Profile, inst.AccountID, inst.Region))
 	}
 	w.Flush()
-	fmt.Println(strings.Repeat("=", 120))
 }
 
+// selectRDSInstance asks the user to pick an RDS instance, listing favorites
+// and recently used databases first
 func selectRDSInstance(instances []RDSInstance) (RDSInstance, error) {
-	var options []string
-	for _, inst := range instances {
-		options = append(options, fmt.Sprintf("%s (%s) - %s", inst.Identifier, inst.Engine, inst.Status))
+	h := loadHistory()
+	lookup := func(i int) (historyEntry, bool) {
+		return h.lookup(historyKindRDS, instances[i].Profile, instances[i].Region, instances[i].Identifier)
 	}
-
-	var selected string
-	prompt := &survey.Select{
-		Message:  "Select an RDS instance:",
-		Options:  options,
-		PageSize: 10,
+	order := pickerOrder(len(instances), lookup)
+
+	var options, search []string
+	for _, i := range order {
+		inst := instances[i]
+		option := fmt.Sprintf("%s (%s) - %s", inst.Identifier, inst.Engine, inst.Status)
+		if location := locationLabel(inst.Profile, inst.Region); location != "" {
+			option = fmt.Sprintf("%s (%s, %s) - %s", inst.Identifier, inst.Engine, location, inst.Status)
+		}
+		options = append(options, historyMark(lookup(i))+option)
+		search = append(search, rdsSearchText(inst))
 	}
 
-	err := survey.AskOne(prompt, &selected)
+	index, err := fuzzySelect("Select an RDS instance:", options, search, func(i int) string {
+		return rdsPreview(instances[order[i]])
+	})
 	if err != nil {
 		return RDSInstance{}, err
 	}
 
-	// Find the selected instance by matching the formatted string
-	for i, opt := range options {
-		if opt == selected {
-			return instances[i], nil
-		}
-	}
-
-	return RDSInstance{}, fmt.Errorf("selected RDS instance not found")
+	return instances[order[index]], nil
 }
 
-func promptForUsername() (string, error) {
+// promptForUsername asks for the database username, defaulting to the one
+// last used with the instance
+func promptForUsername(instance RDSInstance) (string, error) {
 	var username string
 	prompt := &survey.Input{
 		Message: "Enter database username:",
 	}
+	if entry, ok := loadHistory().lookup(historyKindRDS, instance.Profile, instance.Region, instance.Identifier); ok {
+		prompt.Default = entry.Username
+	}
 
 	err := survey.AskOne(prompt, &username, survey.WithValidator(survey.Required))
 	if err != nil {
@@ -539,6 +1511,9 @@ func promptForUsername() (string, error) {
 }
 
 func generateRDSAuthToken(ctx context.Context, cfg aws.Config, instance RDSInstance, username string) error {
+	// Tokens are signed with the instance's own account and region
+	cfg = accountConfig(cfg, instance.Profile, instance.Region)
+
 	if instance.Status != "available" {
 		fmt.Printf("Warning: RDS instance %s is not in 'available' state (current state: %s)\n", instance.Identifier, instance.Status)
 	}
@@ -557,48 +1532,71 @@ func generateRDSAuthToken(ctx context.Context, cfg aws.Config, instance RDSInsta
 	fmt.Printf("  Region:   %s\n", cfg.Region)
 	fmt.Println()
 
-	// Generate the auth token (valid for 15 minutes)
+	authToken, err := buildRDSAuthToken(ctx, cfg, instance, username)
+	if err != nil {
+		return err
+	}
+
+	printRDSAuthToken(authToken)
+	printConnectionExamples(instance.Engine, instance.Endpoint, instance.Port, username, authToken)
+	printRDSNotes()
+
+	return nil
+}
+
+// buildRDSAuthToken generates an IAM auth token (valid for 15 minutes) for the
+// instance's real endpoint. The token is bound to that hostname, so it is
+// signed for the endpoint even when connecting through a tunnel.
+func buildRDSAuthToken(ctx context.Context, cfg aws.Config, instance RDSInstance, username string) (string, error) {
+	endpoint := fmt.Sprintf("%s:%d", instance.Endpoint, instance.Port)
+
 	authToken, err := auth.BuildAuthToken(ctx, endpoint, cfg.Region, username, cfg.Credentials)
 	if err != nil {
-		return fmt.Errorf("failed to generate auth token: %w", err)
+		return "", fmt.Errorf("failed to generate auth token: %w", err)
 	}
 
+	return authToken, nil
+}
+
+func printRDSAuthToken(authToken string) {
 	fmt.Println("IAM Authentication Token (valid for 15 minutes):")
 	fmt.Println(strings.Repeat("=", 120))
 	fmt.Println(authToken)
 	fmt.Println(strings.Repeat("=", 120))
 	fmt.Println()
+}
 
-	// Display connection examples
+// printConnectionExamples shows client commands for the engine connecting to host:port
+func printConnectionExamples(engine, host string, port int32, username, authToken string) {
 	fmt.Println("Connection Examples:")
 	fmt.Println()
 
 	// MySQL/MariaDB
-	if strings.Contains(strings.ToLower(instance.Engine), "mysql") || strings.Contains(strings.ToLower(instance.Engine), "mariadb") {
+	if strings.Contains(strings.ToLower(engine), "mysql") || strings.Contains(strings.ToLower(engine), "mariadb") {
 		fmt.Println("MySQL/MariaDB:")
 		fmt.Printf("  mysql -h %s -P %d -u %s --password='%s' --enable-cleartext-plugin --ssl-mode=REQUIRED\n",
-			instance.Endpoint, instance.Port, username, authToken)
+			host, port, username, authToken)
 		fmt.Println()
 	}
 
 	// PostgreSQL
-	if strings.Contains(strings.ToLower(instance.Engine), "postgres") {
+	if strings.Contains(strings.ToLower(engine), "postgres") {
 		fmt.Println("PostgreSQL:")
 		fmt.Printf("  psql \"host=%s port=%d dbname=your_database user=%s password=%s sslmode=require\"\n",
-			instance.Endpoint, instance.Port, username, authToken)
+			host, port, username, authToken)
 		fmt.Println()
 		fmt.Println("Or using environment variable:")
 		fmt.Printf("  export PGPASSWORD='%s'\n", authToken)
-		fmt.Printf("  psql -h %s -p %d -U %s -d your_database\n", instance.Endpoint, instance.Port, username)
+		fmt.Printf("  psql -h %s -p %d -U %s -d your_database\n", host, port, username)
 		fmt.Println()
 	}
+}
 
+func printRDSNotes() {
 	fmt.Println("Notes:")
 	fmt.Println("  - Token is valid for 15 minutes from generation time")
 	fmt.Println("  - IAM database authentication must be enabled on the RDS instance")
 	fmt.Println("  - The database user must be configured to use IAM authentication")
 	fmt.Println("  - SSL/TLS connection is required")
 	fmt.Printf("  - Generated at: %s\n", time.Now().Format(time.RFC3339))
-
-	return nil
 }
@@ -1,8 +1,18 @@
 package main
 
 import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"reflect"
 	"strings"
 	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ec2/types"
 )
 
 func TestVersion(t *testing.T) {
@@ -380,3 +390,218 @@ func TestRDSEngineVersions(t *testing.T) {
 		}
 	}
 }
+
+func TestInstanceSSMReachable(t *testing.T) {
+	tests := []struct {
+		pingStatus string
+		want       bool
+	}{
+		{"Online", true},
+		{"", true},
+		{"ConnectionLost", false},
+		{"Inactive", false},
+		{pingStatusNotManaged, false},
+	}
+
+	for _, tt := range tests {
+		inst := Instance{ID: "i-test", PingStatus: tt.pingStatus}
+		if got := inst.ssmReachable(); got != tt.want {
+			t.Errorf("PingStatus %q: expected reachable=%v, got %v", tt.pingStatus, tt.want, got)
+		}
+	}
+}
+
+// fakeAWS returns a config whose requests are answered by the handler for
+// their operation, e.g. "DescribeInstances" or "GetCommandInvocation"
+func fakeAWS(t *testing.T, handlers map[string]http.HandlerFunc) aws.Config {
+	t.Helper()
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// JSON services name the operation in a header, EC2 in the form
+		operation := r.Header.Get("X-Amz-Target")
+		if operation != "" {
+			operation = operation[strings.LastIndex(operation, ".")+1:]
+			w.Header().Set("Content-Type", "application/x-amz-json-1.1")
+		} else {
+			r.ParseForm()
+			operation = r.Form.Get("Action")
+			w.Header().Set("Content-Type", "text/xml")
+		}
+
+		handler, ok := handlers[operation]
+		if !ok {
+			t.Errorf("Unexpected AWS call %s", operation)
+			http.Error(w, "{}", http.StatusBadRequest)
+			return
+		}
+		handler(w, r)
+	}))
+	t.Cleanup(server.Close)
+
+	return aws.Config{
+		Region:           "us-east-1",
+		BaseEndpoint:     aws.String(server.URL),
+		Credentials:      aws.AnonymousCredentials{},
+		RetryMaxAttempts: 1,
+	}
+}
+
+func TestRefreshInstances(t *testing.T) {
+	var requested []string
+	cfg := fakeAWS(t, map[string]http.HandlerFunc{
+		"DescribeInstances": func(w http.ResponseWriter, r *http.Request) {
+			requested = []string{r.Form.Get("Filter.1.Value.1"), r.Form.Get("Filter.1.Value.2"), r.Form.Get("Filter.1.Value.3")}
+			fmt.Fprint(w, `<DescribeInstancesResponse><reservationSet><item><instancesSet>
+				<item><instanceId>i-2</instanceId><instanceState><name>stopped</name></instanceState></item>
+				<item><instanceId>i-1</instanceId><instanceState><name>running</name></instanceState>
+					<ipAddress>54.0.0.2</ipAddress>
+					<tagSet><item><key>Name</key><value>web-1</value></item></tagSet></item>
+			</instancesSet></item></reservationSet></DescribeInstancesResponse>`)
+		},
+		"DescribeInstanceInformation": func(w http.ResponseWriter, r *http.Request) {
+			fmt.Fprint(w, `{"InstanceInformationList": [{"InstanceId": "i-1", "PingStatus": "Online", "AgentVersion": "3.3.0"}]}`)
+		},
+	})
+
+	// As cached: i-1 was stopped with an old IP, i-2 was running and online,
+	// and i-3 has since been terminated and purged
+	cached := []Instance{
+		{ID: "i-1", Name: "web-1", State: "stopped", PublicIP: "54.0.0.1", Profile: "prod", AccountID: "123456789012", Region: "us-east-1"},
+		{ID: "i-3", Name: "web-3", State: "running", Profile: "prod", AccountID: "123456789012", Region: "us-east-1"},
+		{ID: "i-2", Name: "web-2", State: "running", PingStatus: "Online", Profile: "prod", AccountID: "123456789012", Region: "us-east-1"},
+	}
+
+	got, gone, err := refreshInstances(context.Background(), cfg, cached)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if requested[0] != "i-1" || requested[1] != "i-3" || requested[2] != "i-2" {
+		t.Errorf("Expected only the given instances to be described, got %q", requested)
+	}
+	if len(got) != 2 || got[0].ID != "i-1" || got[1].ID != "i-2" {
+		t.Fatalf("Expected the instances in the same order, got %+v", got)
+	}
+	if got[0].State != "running" || got[0].PublicIP != "54.0.0.2" || got[0].PingStatus != "Online" || got[0].Name != "web-1" {
+		t.Errorf("Expected i-1 to be running and online with its new IP, got %+v", got[0])
+	}
+	if got[1].State != "stopped" || got[1].PingStatus != pingStatusNotManaged {
+		t.Errorf("Expected i-2 to be stopped and no longer reported by SSM, got %+v", got[1])
+	}
+	if got[0].Profile != "prod" || got[0].AccountID != "123456789012" || got[0].Region != "us-east-1" {
+		t.Errorf("Expected the account and region to be kept, got %+v", got[0])
+	}
+
+	if len(gone) != 1 || gone[0].ID != "i-3" {
+		t.Errorf("Expected i-3 to be reported as gone, got %+v", gone)
+	}
+
+	if _, err := refreshInstance(context.Background(), cfg, Instance{ID: "i-3", Region: "us-east-1"}); err == nil {
+		t.Errorf("Expected an error for an instance that no longer exists")
+	}
+}
+
+func TestListRegionInstancesExtraFilters(t *testing.T) {
+	defer func() { ec2ExtraFilters = nil }()
+
+	var form url.Values
+	cfg := fakeAWS(t, map[string]http.HandlerFunc{
+		"DescribeInstances": func(w http.ResponseWriter, r *http.Request) {
+			form = r.Form
+			fmt.Fprint(w, `<DescribeInstancesResponse><reservationSet/></DescribeInstancesResponse>`)
+		},
+	})
+
+	// A Name containing a comma must reach EC2 as a single value
+	ec2ExtraFilters = []types.Filter{{Name: aws.String("tag:Name"), Values: []string{"web,blue"}}}
+	if _, err := listRegionInstances(context.Background(), cfg); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	if form.Get("Filter.2.Name") != "tag:Name" || form.Get("Filter.2.Value.1") != "web,blue" || form.Get("Filter.2.Value.2") != "" {
+		t.Errorf("Expected the extra filter to be sent as is, got %v", form)
+	}
+}
+
+func TestTruncateResults(t *testing.T) {
+	defer func() { maxResults = 0 }()
+
+	tests := []struct {
+		name          string
+		max           int
+		items         int
+		more          bool
+		wantLen       int
+		wantTruncated bool
+	}{
+		{"no limit", 0, 5, true, 5, false},
+		{"under the limit", 3, 2, false, 2, false},
+		{"exactly the limit", 3, 3, false, 3, false},
+		{"exactly the limit with more pages", 3, 3, true, 3, true},
+		{"over the limit", 3, 5, false, 3, true},
+	}
+
+	for _, tt := range tests {
+		maxResults = tt.max
+		got, truncated := truncateResults(make([]Instance, tt.items), tt.more)
+		if len(got) != tt.wantLen || truncated != tt.wantTruncated {
+			t.Errorf("%s: expected %d items (truncated %v), got %d (truncated %v)", tt.name, tt.wantLen, tt.wantTruncated, len(got), truncated)
+		}
+	}
+}
+
+func TestNewInstance(t *testing.T) {
+	launched := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+
+	got := newInstance(types.Instance{
+		InstanceId:       aws.String("i-0123456789abcdef0"),
+		InstanceType:     types.InstanceTypeT3Micro,
+		VpcId:            aws.String("vpc-1"),
+		LaunchTime:       &launched,
+		ImageId:          aws.String("ami-0abc123"),
+		PlatformDetails:  aws.String("Linux/UNIX"),
+		State:            &types.InstanceState{Name: types.InstanceStateNameRunning},
+		Placement:        &types.Placement{AvailabilityZone: aws.String("us-east-1a")},
+		PrivateIpAddress: aws.String("10.0.1.100"),
+		PublicIpAddress:  aws.String("54.123.45.67"),
+		Tags: []types.Tag{
+			{Key: aws.String("Name"), Value: aws.String("web-1")},
+			{Key: aws.String("Env"), Value: aws.String("prod")},
+		},
+	})
+
+	want := Instance{
+		ID:               "i-0123456789abcdef0",
+		Name:             "web-1",
+		PrivateIP:        "10.0.1.100",
+		PublicIP:         "54.123.45.67",
+		State:            "running",
+		InstanceType:     "t3.micro",
+		Platform:         "linux",
+		VpcID:            "vpc-1",
+		Tags:             map[string]string{"Name": "web-1", "Env": "prod"},
+		AvailabilityZone: "us-east-1a",
+		LaunchTime:       launched,
+		ImageID:          "ami-0abc123",
+		PlatformDetails:  "Linux/UNIX",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Expected %+v, got %+v", want, got)
+	}
+
+	// Windows is detected from either the platform or the platform details
+	windows := []types.Instance{
+		{InstanceId: aws.String("i-1"), Platform: types.PlatformValuesWindows},
+		{InstanceId: aws.String("i-2"), PlatformDetails: aws.String("Windows with SQL Server Standard")},
+	}
+	for _, instance := range windows {
+		if got := newInstance(instance); got.Platform != "windows" {
+			t.Errorf("Expected %s to be a windows instance, got %q", got.ID, got.Platform)
+		}
+	}
+
+	// Missing optional fields leave the instance's fields empty
+	bare := newInstance(types.Instance{InstanceId: aws.String("i-3")})
+	if bare.Name != "" || bare.State != "" || bare.AvailabilityZone != "" || bare.Tags == nil {
+		t.Errorf("Expected empty fields and an empty tag map, got %+v", bare)
+	}
+}
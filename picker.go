@@ -0,0 +1,187 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/AlecAivazis/survey/v2"
+)
+
+// previewSelectTemplate is survey's Select template with the option
+// descriptions moved into a preview of the highlighted option below the list
+var previewSelectTemplate = `
+{{- define "option"}}
+    {{- if eq .SelectedIndex .CurrentIndex }}{{color .Config.Icons.SelectFocus.Format }}{{ .Config.Icons.SelectFocus.Text }} {{else}}{{color "default"}}  {{end}}
+    {{- .CurrentOpt.Value}}
+    {{- color "reset"}}
+{{end}}
+{{- if .ShowHelp }}{{- color .Config.Icons.Help.Format }}{{ .Config.Icons.Help.Text }} {{ .Help }}{{color "reset"}}{{"\n"}}{{end}}
+{{- color .Config.Icons.Question.Format }}{{ .Config.Icons.Question.Text }} {{color "reset"}}
+{{- color "default+hb"}}{{ .Message }}{{ .FilterMessage }}{{color "reset"}}
+{{- if .ShowAnswer}}{{color "cyan"}} {{.Answer}}{{color "reset"}}{{"\n"}}
+{{- else}}
+  {{- "  "}}{{- color "cyan"}}[Use arrows to move, type to search{{- if and .Help (not .ShowHelp)}}, {{ .Config.HelpInput }} for more help{{end}}]{{color "reset"}}
+  {{- "\n"}}
+  {{- range $ix, $option := .PageEntries}}
+    {{- template "option" $.IterateOption $ix $option}}
+  {{- end}}
+  {{- range $ix, $option := .PageEntries}}
+    {{- if eq $ix $.SelectedIndex }}{{ with $.GetDescription $option }}{{"\n"}}{{color "cyan"}}{{ . }}{{color "reset"}}{{"\n"}}{{end}}{{end}}
+  {{- end}}
+{{- end}}`
+
+// fuzzySelect asks the user to pick one of options and returns its index.
+// Typing narrows the list to options whose search text fuzzy-matches every
+// word typed, and preview describes the highlighted option below the list.
+func fuzzySelect(message string, options, search []string, preview func(index int) string) (int, error) {
+	// survey renders every Select with the package-level template, so it is
+	// only swapped for this prompt and restored for any other Select
+	defaultTemplate := survey.SelectQuestionTemplate
+	survey.SelectQuestionTemplate = previewSelectTemplate
+	defer func() { survey.SelectQuestionTemplate = defaultTemplate }()
+
+	var index int
+	prompt := &survey.Select{
+		Message:  message,
+		Options:  options,
+		PageSize: 10,
+		Filter: func(filter, value string, i int) bool {
+			return fuzzyMatchAll(filter, search[i])
+		},
+		Description: func(value string, i int) string {
+			return preview(i)
+		},
+	}
+
+	if err := survey.AskOne(prompt, &index); err != nil {
+		return 0, err
+	}
+	return index, nil
+}
+
+// fuzzyMatchAll reports whether every whitespace-separated word of query
+// fuzzy-matches text
+func fuzzyMatchAll(query, text string) bool {
+	for _, word := range strings.Fields(query) {
+		if !fuzzyMatch(word, text) {
+			return false
+		}
+	}
+	return true
+}
+
+// fuzzyMatch reports whether the characters of pattern appear in text in
+// order, ignoring case (e.g. "wb1" matches "web-1")
+func fuzzyMatch(pattern, text string) bool {
+	remaining := []rune(strings.ToLower(pattern))
+	for _, r := range strings.ToLower(text) {
+		if len(remaining) == 0 {
+			break
+		}
+		if r == remaining[0] {
+			remaining = remaining[1:]
+		}
+	}
+	return len(remaining) == 0
+}
+
+// instanceSearchText is what the picker's search matches an instance against
+func instanceSearchText(inst Instance) string {
+	fields := []string{inst.Name, inst.ID, inst.PrivateIP, inst.PublicIP, inst.State,
+		inst.InstanceType, inst.Platform, inst.AvailabilityZone, inst.Region, inst.Profile, inst.AccountID}
+	return strings.Join(append(fields, tagPairs(inst.Tags)...), " ")
+}
+
+// rdsSearchText is what the picker's search matches an RDS instance against
+func rdsSearchText(inst RDSInstance) string {
+	fields := []string{inst.Identifier, inst.Engine, inst.EngineVersion, inst.Status, inst.InstanceClass,
+		inst.Endpoint, inst.AvailabilityZone, inst.Region, inst.Profile, inst.AccountID}
+	return strings.Join(append(fields, tagPairs(inst.Tags)...), " ")
+}
+
+// instancePreview describes an instance for the picker's preview
+func instancePreview(inst Instance) string {
+	var b strings.Builder
+	writePreviewField(&b, "Instance", inst.ID)
+	writePreviewField(&b, "Name", inst.Name)
+	writePreviewField(&b, "State", inst.State)
+	writePreviewField(&b, "Type", inst.InstanceType)
+	writePreviewField(&b, "Platform", inst.Platform)
+	writePreviewField(&b, "Private IP", inst.PrivateIP)
+	writePreviewField(&b, "Public IP", inst.PublicIP)
+	writePreviewField(&b, "VPC", inst.VpcID)
+	writePreviewField(&b, "AZ", inst.AvailabilityZone)
+	writePreviewField(&b, "Region", inst.Region)
+	if multiAccount() {
+		writePreviewField(&b, "Account", accountLabel(inst.Profile, inst.AccountID))
+	}
+	if !inst.LaunchTime.IsZero() {
+		writePreviewField(&b, "Launched", inst.LaunchTime.Local().Format(time.RFC1123))
+	}
+	writePreviewField(&b, "SSM agent", strings.TrimSpace(inst.PingStatus+" "+inst.AgentVersion))
+	writePreviewTags(&b, inst.Tags)
+	return strings.TrimRight(b.String(), "\n")
+}
+
+// rdsPreview describes an RDS instance for the picker's preview
+func rdsPreview(inst RDSInstance) string {
+	var b strings.Builder
+	writePreviewField(&b, "Identifier", inst.Identifier)
+	writePreviewField(&b, "Engine", strings.TrimSpace(inst.Engine+" "+inst.EngineVersion))
+	writePreviewField(&b, "Status", inst.Status)
+	writePreviewField(&b, "Class", inst.InstanceClass)
+	if inst.Endpoint != "" {
+		writePreviewField(&b, "Endpoint", fmt.Sprintf("%s:%d", inst.Endpoint, inst.Port))
+	}
+	writePreviewField(&b, "VPC", inst.VpcID)
+	writePreviewField(&b, "AZ", inst.AvailabilityZone)
+	writePreviewField(&b, "Region", inst.Region)
+	if multiAccount() {
+		writePreviewField(&b, "Account", accountLabel(inst.Profile, inst.AccountID))
+	}
+	writePreviewTags(&b, inst.Tags)
+	return strings.TrimRight(b.String(), "\n")
+}
+
+func writePreviewField(b *strings.Builder, label, value string) {
+	if value != "" {
+		fmt.Fprintf(b, "  %-11s %s\n", label+":", value)
+	}
+}
+
+// writePreviewTags writes the tags other than Name, one per line
+func writePreviewTags(b *strings.Builder, tags map[string]string) {
+	first := true
+	for _, pair := range tagPairs(tags) {
+		if strings.HasPrefix(pair, "Name=") {
+			continue
+		}
+		label := ""
+		if first {
+			label = "Tags:"
+			first = false
+		}
+		fmt.Fprintf(b, "  %-11s %s\n", label, pair)
+	}
+}
+
+// tagPairs returns tags as sorted key=value strings
+func tagPairs(tags map[string]string) []string {
+	var pairs []string
+	for k, v := range tags {
+		pairs = append(pairs, k+"="+strings.Map(printable, v))
+	}
+	sort.Strings(pairs)
+	return pairs
+}
+
+// printable replaces control characters, which would break the picker's layout
+func printable(r rune) rune {
+	if unicode.IsControl(r) {
+		return ' '
+	}
+	return r
+}
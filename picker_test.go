@@ -0,0 +1,110 @@
+package main
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/AlecAivazis/survey/v2"
+	"github.com/AlecAivazis/survey/v2/core"
+)
+
+func TestFuzzyMatch(t *testing.T) {
+	tests := []struct {
+		pattern string
+		text    string
+		want    bool
+	}{
+		{"web", "web-1", true},
+		{"wb1", "web-1", true},
+		{"WEB", "web-1", true},
+		{"1bew", "web-1", false},
+		{"", "anything", true},
+		{"10.0.1", "api i-0abc 10.0.1.25", true},
+		{"xyz", "web-1", false},
+	}
+
+	for _, tt := range tests {
+		if got := fuzzyMatch(tt.pattern, tt.text); got != tt.want {
+			t.Errorf("%q in %q: expected %v, got %v", tt.pattern, tt.text, tt.want, got)
+		}
+	}
+}
+
+func TestFuzzyMatchAll(t *testing.T) {
+	text := instanceSearchText(Instance{
+		ID:        "i-0123456789abcdef0",
+		Name:      "web-1",
+		PrivateIP: "10.0.1.25",
+		Tags:      map[string]string{"Env": "prod", "Team": "payments"},
+	})
+
+	tests := []struct {
+		query string
+		want  bool
+	}{
+		{"web prod", true},
+		{"env=prod pay", true},
+		{"10.0.1.25", true},
+		{"web staging", false},
+		{"  ", true},
+	}
+
+	for _, tt := range tests {
+		if got := fuzzyMatchAll(tt.query, text); got != tt.want {
+			t.Errorf("%q: expected %v, got %v", tt.query, tt.want, got)
+		}
+	}
+}
+
+func TestInstancePreview(t *testing.T) {
+	preview := instancePreview(Instance{
+		ID:               "i-0123456789abcdef0",
+		Name:             "web-1",
+		State:            "running",
+		InstanceType:     "t3.micro",
+		PrivateIP:        "10.0.1.25",
+		AvailabilityZone: "us-east-1a",
+		LaunchTime:       time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC),
+		Tags:             map[string]string{"Name": "web-1", "Env": "prod", "Note": "line1\nline2"},
+	})
+
+	for _, want := range []string{"i-0123456789abcdef0", "t3.micro", "10.0.1.25", "us-east-1a", "2024", "Env=prod", "Note=line1 line2"} {
+		if !strings.Contains(preview, want) {
+			t.Errorf("Expected preview to contain %q, got:\n%s", want, preview)
+		}
+	}
+	if strings.Contains(preview, "Public IP") {
+		t.Errorf("Expected empty fields to be left out, got:\n%s", preview)
+	}
+	if strings.Contains(preview, "Name=web-1") {
+		t.Errorf("Expected the Name tag to be left out of the tags, got:\n%s", preview)
+	}
+}
+
+func TestPreviewSelectTemplate(t *testing.T) {
+	options := []string{"web-1 (i-1) - running", "web-2 (i-2) - running"}
+	describe := func(value string, index int) string {
+		return "details of " + value
+	}
+
+	data := survey.SelectTemplateData{
+		Select:        survey.Select{Message: "Pick one:", Options: options},
+		PageEntries:   core.OptionAnswerList(options),
+		SelectedIndex: 1,
+		Description:   describe,
+		Config:        &survey.PromptConfig{},
+	}
+
+	output, _, err := core.RunTemplate(previewSelectTemplate, data)
+	if err != nil {
+		t.Fatalf("Failed to render template: %v", err)
+	}
+
+	if strings.Count(output, "details of") != 1 || !strings.Contains(output, "details of web-2") {
+		t.Errorf("Expected only the highlighted option to be previewed, got:\n%s", output)
+	}
+	if strings.Index(output, "details of") < strings.Index(output, options[1]) {
+		t.Errorf("Expected the preview below the options, got:\n%s", output)
+	}
+}
@@ -0,0 +1,96 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net"
+	"strconv"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ssm"
+)
+
+func handleEC2PortForward(ctx context.Context, cfg aws.Config, target string) {
+	instances, err := listInstances(ctx, cfg)
+	if err != nil {
+		log.Fatalf("Failed to list instances: %v", err)
+	}
+
+	selectedInstance, err := resolveInstance(instances, target)
+	if err != nil {
+		log.Fatalf("Failed to resolve target: %v", err)
+	}
+
+	err = forwardPort(ctx, cfg, selectedInstance, remotePort, localPort)
+	if err != nil {
+		fatalSession("Failed to forward port: %v", err)
+	}
+}
+
+// forwardPort forwards localhost:localPort to remotePort on the instance
+// until the session is interrupted. A free local port is picked when
+// localPort is 0.
+func forwardPort(ctx context.Context, cfg aws.Config, instance Instance, remotePort, localPort int) error {
+	cfg = accountConfig(cfg, instance.Profile, instance.Region)
+
+	instance, err := refreshInstance(ctx, cfg, instance)
+	if err != nil {
+		return err
+	}
+
+	instance, err = ensureInstanceRunning(ctx, cfg, instance)
+	if err != nil {
+		return err
+	}
+
+	if err := checkSessionTarget(instance); err != nil {
+		return err
+	}
+
+	if err := validatePort(remotePort); err != nil {
+		return fmt.Errorf("invalid remote port: %w", err)
+	}
+
+	if localPort == 0 {
+		port, err := freeLocalPort()
+		if err != nil {
+			return fmt.Errorf("failed to find a free local port: %w", err)
+		}
+		localPort = port
+	} else if err := validatePort(localPort); err != nil {
+		return fmt.Errorf("invalid local port: %w", err)
+	}
+
+	fmt.Printf("\nStarting port forwarding session to %s (%s)...\n", instance.Name, instance.ID)
+
+	startSessionInput := &ssm.StartSessionInput{
+		Target:       aws.String(instance.ID),
+		DocumentName: aws.String("AWS-StartPortForwardingSession"),
+		Parameters: map[string][]string{
+			"portNumber":      {strconv.Itoa(remotePort)},
+			"localPortNumber": {strconv.Itoa(localPort)},
+		},
+	}
+
+	readyMessage := fmt.Sprintf("Forwarding localhost:%d -> %s:%d. Press Ctrl+C to stop.", localPort, instance.ID, remotePort)
+	return runSession(ctx, cfg, startSessionInput, readyMessage, nil)
+}
+
+// freeLocalPort asks the kernel for an unused TCP port on the loopback interface
+func freeLocalPort() (int, error) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return 0, err
+	}
+	defer listener.Close()
+
+	return listener.Addr().(*net.TCPAddr).Port, nil
+}
+
+func validatePort(port int) error {
+	if port < 1 || port > 65535 {
+		return fmt.Errorf("port %d is out of range (1-65535)", port)
+	}
+	return nil
+}
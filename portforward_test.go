@@ -0,0 +1,44 @@
+package main
+
+import (
+	"fmt"
+	"net"
+	"testing"
+)
+
+func TestFreeLocalPort(t *testing.T) {
+	port, err := freeLocalPort()
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if err := validatePort(port); err != nil {
+		t.Fatalf("Expected a valid port, got %d", port)
+	}
+
+	// The port must be usable once freeLocalPort has released it
+	listener, err := net.Listen("tcp", fmt.Sprintf("127.0.0.1:%d", port))
+	if err != nil {
+		t.Fatalf("Expected port %d to be free: %v", port, err)
+	}
+	listener.Close()
+}
+
+func TestValidatePort(t *testing.T) {
+	tests := []struct {
+		port    int
+		wantErr bool
+	}{
+		{0, true},
+		{1, false},
+		{5432, false},
+		{65535, false},
+		{65536, true},
+		{-1, true},
+	}
+
+	for _, tt := range tests {
+		if err := validatePort(tt.port); (err != nil) != tt.wantErr {
+			t.Errorf("Port %d: expected error=%v, got %v", tt.port, tt.wantErr, err)
+		}
+	}
+}
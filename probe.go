@@ -0,0 +1,89 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ssm"
+)
+
+// probeTimeout bounds a Run Command probe of an instance before a session
+const probeTimeout = 30 * time.Second
+
+// runProbe runs a short script on the instance with Run Command and returns
+// its output
+func runProbe(ctx context.Context, cfg aws.Config, instance Instance, script string) (string, error) {
+	ctx, cancel := context.WithTimeout(ctx, probeTimeout)
+	defer cancel()
+
+	ssmClient := ssm.NewFromConfig(cfg)
+	output, err := ssmClient.SendCommand(ctx, &ssm.SendCommandInput{
+		DocumentName: aws.String(runCommandDocument(instance.Platform)),
+		InstanceIds:  []string{instance.ID},
+		Parameters:   map[string][]string{"commands": {script}},
+		Comment:      aws.String("aws-go-tools session probe"),
+	})
+	if err != nil {
+		return "", fmt.Errorf("failed to send command: %w", err)
+	}
+
+	result := waitForInvocation(ctx, ssmClient, aws.ToString(output.Command.CommandId), instance)
+	if result.Err != nil {
+		return "", result.Err
+	}
+	if !result.succeeded() {
+		return "", fmt.Errorf("probe %s: %s", result.Status, strings.TrimSpace(result.Stderr))
+	}
+	return result.Stdout, nil
+}
+
+// errNoSuchUser is returned when the run-as user does not exist on the instance
+var errNoSuchUser = errors.New("no such user")
+
+// userProbeScript returns a script that prints whether user exists
+func userProbeScript(user string) string {
+	return fmt.Sprintf("if id -u %s >/dev/null 2>&1; then echo found; else echo missing; fi", shellQuote(user))
+}
+
+// parseUserProbe interprets the output of userProbeScript
+func parseUserProbe(stdout string) (bool, error) {
+	for _, line := range strings.Split(stdout, "\n") {
+		switch strings.TrimSpace(line) {
+		case "found":
+			return true, nil
+		case "missing":
+			return false, nil
+		}
+	}
+	return false, fmt.Errorf("unexpected probe output %q", strings.TrimSpace(stdout))
+}
+
+// checkRunAsUser verifies that user exists on a Linux instance before a
+// session starts as it. A probe that can't run only warns, since Run Command
+// may not be allowed where sessions are.
+func checkRunAsUser(ctx context.Context, cfg aws.Config, instance Instance, user string) error {
+	if instance.Platform == "windows" {
+		return nil
+	}
+
+	fmt.Fprintf(os.Stderr, "Checking that user %s exists on %s...\n", user, displayName(instance))
+	stdout, err := runProbe(ctx, cfg, instance, userProbeScript(user))
+	var found bool
+	if err == nil {
+		found, err = parseUserProbe(stdout)
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: could not check that user %s exists on %s: %v\n", user, instance.ID, err)
+		return nil
+	}
+
+	if !found {
+		return fmt.Errorf("can't run as %s on %s: %w", user, instance.ID, errNoSuchUser)
+	}
+	return nil
+}
@@ -0,0 +1,43 @@
+package main
+
+import "testing"
+
+func TestUserProbeScript(t *testing.T) {
+	tests := []struct {
+		user string
+		want string
+	}{
+		{"app", "if id -u app >/dev/null 2>&1; then echo found; else echo missing; fi"},
+		{"app; reboot", "if id -u 'app; reboot' >/dev/null 2>&1; then echo found; else echo missing; fi"},
+	}
+
+	for _, tt := range tests {
+		if got := userProbeScript(tt.user); got != tt.want {
+			t.Errorf("userProbeScript(%q): expected %q, got %q", tt.user, tt.want, got)
+		}
+	}
+}
+
+func TestParseUserProbe(t *testing.T) {
+	tests := []struct {
+		stdout  string
+		want    bool
+		wantErr bool
+	}{
+		{"found\n", true, false},
+		{"Last login: yesterday\r\nmissing\r\n", false, false},
+		{"", false, true},
+		{"permission denied\n", false, true},
+	}
+
+	for _, tt := range tests {
+		got, err := parseUserProbe(tt.stdout)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("parseUserProbe(%q): expected error %v, got %v", tt.stdout, tt.wantErr, err)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("parseUserProbe(%q): expected %v, got %v", tt.stdout, tt.want, got)
+		}
+	}
+}
@@ -0,0 +1,164 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ec2/types"
+	"github.com/aws/aws-sdk-go-v2/service/ssm"
+)
+
+func handleRDSTunnel(ctx context.Context, cfg aws.Config) {
+	rdsInstances, err := listRDSInstances(ctx, cfg)
+	if err != nil {
+		log.Fatalf("Failed to list RDS instances: %v", err)
+	}
+
+	if len(rdsInstances) == 0 {
+		fmt.Println("No RDS instances found")
+		return
+	}
+
+	selectedRDS, err := selectRDSInstance(rdsInstances)
+	if err != nil {
+		log.Fatalf("Failed to select RDS instance: %v", err)
+	}
+
+	instances, err := listInstances(ctx, cfg)
+	if err != nil {
+		log.Fatalf("Failed to list instances: %v", err)
+	}
+
+	bastion, err := findBastion(instances, selectedRDS, bastionTarget)
+	if err != nil {
+		log.Fatalf("Failed to find a bastion: %v", err)
+	}
+
+	username, err := promptForUsername(selectedRDS)
+	if err != nil {
+		log.Fatalf("Failed to get username: %v", err)
+	}
+
+	rememberRDS(selectedRDS, username)
+
+	err = tunnelToRDS(ctx, cfg, selectedRDS, bastion, username, localPort)
+	if err != nil {
+		fatalSession("Failed to open tunnel: %v", err)
+	}
+}
+
+// findBastion picks the EC2 instance to tunnel through. An explicit target is
+// resolved like "ec2 connect"; otherwise running, SSM-reachable instances in
+// the database's VPC are considered, preferring ones named like a bastion.
+func findBastion(instances []Instance, db RDSInstance, target string) (Instance, error) {
+	if target != "" {
+		return resolveInstance(instances, target)
+	}
+
+	if db.VpcID == "" {
+		return Instance{}, fmt.Errorf("RDS instance %s has no VPC; use --bastion to choose an instance", db.Identifier)
+	}
+
+	var candidates, named []Instance
+	for _, inst := range instances {
+		if inst.Region != db.Region || inst.VpcID != db.VpcID || inst.State != string(types.InstanceStateNameRunning) || !inst.ssmReachable() {
+			continue
+		}
+		candidates = append(candidates, inst)
+		if strings.Contains(strings.ToLower(inst.Name), "bastion") || strings.EqualFold(inst.Tags["Role"], "bastion") {
+			named = append(named, inst)
+		}
+	}
+	if len(named) > 0 {
+		candidates = named
+	}
+
+	switch {
+	case len(candidates) == 0:
+		return Instance{}, fmt.Errorf("no running SSM-managed instance found in %s; use --bastion to choose one", db.VpcID)
+	case len(candidates) == 1:
+		return candidates[0], nil
+	case !isInteractive():
+		return Instance{}, fmt.Errorf("%d possible bastions found in %s; use --bastion to choose one", len(candidates), db.VpcID)
+	}
+
+	return selectInstance(candidates)
+}
+
+// tunnelToRDS forwards a local port to the database endpoint through the
+// bastion and prints an IAM auth token and client commands for the local end.
+// The session stays in the foreground until it is interrupted.
+func tunnelToRDS(ctx context.Context, cfg aws.Config, db RDSInstance, bastion Instance, username string, localPort int) error {
+	if bastion.Region != db.Region {
+		return fmt.Errorf("bastion %s is in %s but RDS instance %s is in %s", bastion.ID, bastion.Region, db.Identifier, db.Region)
+	}
+
+	// The bastion may live in another account than the database (shared VPCs)
+	bastionCfg := accountConfig(cfg, bastion.Profile, bastion.Region)
+	dbCfg := accountConfig(cfg, db.Profile, db.Region)
+
+	bastion, err := refreshInstance(ctx, bastionCfg, bastion)
+	if err != nil {
+		return err
+	}
+
+	bastion, err = ensureInstanceRunning(ctx, bastionCfg, bastion)
+	if err != nil {
+		return err
+	}
+
+	if err := checkSessionTarget(bastion); err != nil {
+		return err
+	}
+
+	if db.Endpoint == "" {
+		return fmt.Errorf("RDS instance %s does not have an endpoint", db.Identifier)
+	}
+
+	if localPort == 0 {
+		port, err := freeLocalPort()
+		if err != nil {
+			return fmt.Errorf("failed to find a free local port: %w", err)
+		}
+		localPort = port
+	} else if err := validatePort(localPort); err != nil {
+		return fmt.Errorf("invalid local port: %w", err)
+	}
+
+	// The token is signed for the real endpoint, not for the local end of the tunnel
+	authToken, err := buildRDSAuthToken(ctx, dbCfg, db, username)
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("\nOpening tunnel:\n")
+	fmt.Printf("  Instance: %s\n", db.Identifier)
+	fmt.Printf("  Endpoint: %s:%d\n", db.Endpoint, db.Port)
+	fmt.Printf("  Bastion:  %s (%s)\n", bastion.Name, bastion.ID)
+	fmt.Printf("  Local:    127.0.0.1:%d\n", localPort)
+	fmt.Printf("  Username: %s\n", username)
+	fmt.Println()
+
+	printRDSAuthToken(authToken)
+	printConnectionExamples(db.Engine, "127.0.0.1", int32(localPort), username, authToken)
+	printRDSNotes()
+	fmt.Println("  - Certificate hostname verification (verify-full / VERIFY_IDENTITY) fails through the tunnel")
+	fmt.Println()
+
+	startSessionInput := &ssm.StartSessionInput{
+		Target:       aws.String(bastion.ID),
+		DocumentName: aws.String("AWS-StartPortForwardingSessionToRemoteHost"),
+		Parameters: map[string][]string{
+			"host":            {db.Endpoint},
+			"portNumber":      {strconv.Itoa(int(db.Port))},
+			"localPortNumber": {strconv.Itoa(localPort)},
+		},
+	}
+
+	readyMessage := fmt.Sprintf("Tunnel open on 127.0.0.1:%d. Press Ctrl+C to close it.", localPort)
+	return runSession(ctx, bastionCfg, startSessionInput, readyMessage, nil)
+}
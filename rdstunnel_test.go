@@ -0,0 +1,83 @@
+package main
+
+import (
+	"testing"
+)
+
+func TestFindBastion(t *testing.T) {
+	db := RDSInstance{Identifier: "orders-db", VpcID: "vpc-1"}
+
+	tests := []struct {
+		name      string
+		instances []Instance
+		target    string
+		wantID    string
+		wantErr   bool
+	}{
+		{
+			name: "Only candidate in VPC",
+			instances: []Instance{
+				{ID: "i-1", Name: "app", State: "running", VpcID: "vpc-1", PingStatus: "Online"},
+				{ID: "i-2", Name: "app", State: "running", VpcID: "vpc-2", PingStatus: "Online"},
+			},
+			wantID: "i-1",
+		},
+		{
+			name: "Prefers bastion by name",
+			instances: []Instance{
+				{ID: "i-1", Name: "app", State: "running", VpcID: "vpc-1", PingStatus: "Online"},
+				{ID: "i-2", Name: "prod-bastion", State: "running", VpcID: "vpc-1", PingStatus: "Online"},
+			},
+			wantID: "i-2",
+		},
+		{
+			name: "Prefers bastion by Role tag",
+			instances: []Instance{
+				{ID: "i-1", Name: "app", State: "running", VpcID: "vpc-1", PingStatus: "Online"},
+				{ID: "i-2", Name: "jump", State: "running", VpcID: "vpc-1", PingStatus: "Online", Tags: map[string]string{"Role": "bastion"}},
+			},
+			wantID: "i-2",
+		},
+		{
+			name: "Skips stopped and unmanaged instances",
+			instances: []Instance{
+				{ID: "i-1", Name: "bastion-a", State: "stopped", VpcID: "vpc-1", PingStatus: "ConnectionLost"},
+				{ID: "i-2", Name: "bastion-b", State: "running", VpcID: "vpc-1", PingStatus: pingStatusNotManaged},
+				{ID: "i-3", Name: "app", State: "running", VpcID: "vpc-1", PingStatus: "Online"},
+			},
+			wantID: "i-3",
+		},
+		{
+			name: "Explicit target outside the VPC",
+			instances: []Instance{
+				{ID: "i-1", Name: "bastion", State: "running", VpcID: "vpc-1", PingStatus: "Online"},
+				{ID: "i-2", Name: "peered-jump", State: "running", VpcID: "vpc-2", PingStatus: "Online"},
+			},
+			target: "peered-jump",
+			wantID: "i-2",
+		},
+		{
+			name: "No candidates",
+			instances: []Instance{
+				{ID: "i-1", Name: "bastion", State: "running", VpcID: "vpc-2", PingStatus: "Online"},
+			},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			bastion, err := findBastion(tt.instances, db, tt.target)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Expected error=%v, got %v", tt.wantErr, err)
+			}
+			if bastion.ID != tt.wantID {
+				t.Errorf("Expected bastion %s, got %s", tt.wantID, bastion.ID)
+			}
+		})
+	}
+
+	if _, err := findBastion(nil, RDSInstance{Identifier: "no-vpc"}, ""); err == nil {
+		t.Error("Expected an error for an RDS instance without a VPC")
+	}
+}
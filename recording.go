@@ -0,0 +1,269 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	"golang.org/x/term"
+)
+
+// Asciicast v2 event types
+const (
+	castOutput = "o"
+	castInput  = "i"
+	castResize = "r"
+)
+
+// castHeader is the first line of an asciicast v2 recording
+type castHeader struct {
+	Version   int               `json:"version"`
+	Width     int               `json:"width"`
+	Height    int               `json:"height"`
+	Timestamp int64             `json:"timestamp"`
+	Title     string            `json:"title,omitempty"`
+	Env       map[string]string `json:"env,omitempty"`
+}
+
+// sessionRecorder writes a terminal session as an asciicast v2 file: a header
+// line followed by one [seconds, type, data] event per line. Output and input
+// arrive from different goroutines, so writes are serialized.
+type sessionRecorder struct {
+	mu          sync.Mutex
+	w           io.Writer
+	start       time.Time
+	now         func() time.Time
+	recordInput bool
+	partial     map[string][]byte
+	closer      io.Closer
+	writeErr    error
+}
+
+func newSessionRecorder(w io.Writer, header castHeader, recordInput bool, now func() time.Time) (*sessionRecorder, error) {
+	start := now()
+	header.Version = 2
+	header.Timestamp = start.Unix()
+
+	data, err := json.Marshal(header)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := fmt.Fprintf(w, "%s\n", data); err != nil {
+		return nil, err
+	}
+
+	return &sessionRecorder{w: w, start: start, now: now, recordInput: recordInput, partial: map[string][]byte{}}, nil
+}
+
+// recordingsDir is where session recordings are kept, one directory per instance
+func recordingsDir() string {
+	return filepath.Join(appDataDir(), "recordings")
+}
+
+// startRecording creates a recording file for a session to instance and
+// returns its recorder and path
+func startRecording(instance Instance, recordInput bool) (*sessionRecorder, string, error) {
+	if usePlugin && !pluginRecordingSupported {
+		return nil, "", fmt.Errorf("sessions run with --plugin can't be recorded on this platform")
+	}
+
+	now := time.Now()
+	path := filepath.Join(recordingsDir(), instance.ID, now.UTC().Format("20060102T150405Z")+".cast")
+	if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+		return nil, "", err
+	}
+
+	file, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o600)
+	if err != nil {
+		return nil, "", err
+	}
+
+	width, height := terminalSize()
+	header := castHeader{
+		Width:  width,
+		Height: height,
+		Title:  fmt.Sprintf("%s (%s)", displayName(instance), instance.ID),
+		Env:    map[string]string{"TERM": os.Getenv("TERM"), "SHELL": os.Getenv("SHELL")},
+	}
+
+	rec, err := newSessionRecorder(file, header, recordInput, time.Now)
+	if err != nil {
+		file.Close()
+		return nil, "", err
+	}
+	rec.closer = file
+	return rec, path, nil
+}
+
+// terminalSize returns the size of the terminal on stdout, or 80x24
+func terminalSize() (int, int) {
+	width, height, err := term.GetSize(int(os.Stdout.Fd()))
+	if err != nil || width <= 0 || height <= 0 {
+		return 80, 24
+	}
+	return width, height
+}
+
+// event appends an event. Bytes of a UTF-8 sequence split across writes are
+// held back until the sequence is complete, since events are JSON strings.
+func (r *sessionRecorder) event(kind string, data []byte) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	data = append(r.partial[kind], data...)
+	complete := len(data)
+	for i := len(data) - 1; i >= 0 && i >= len(data)-utf8.UTFMax; i-- {
+		if utf8.RuneStart(data[i]) {
+			if !utf8.FullRune(data[i:]) {
+				complete = i
+			}
+			break
+		}
+	}
+	r.partial[kind] = append([]byte(nil), data[complete:]...)
+
+	if complete > 0 {
+		r.writeEvent(kind, string(data[:complete]))
+	}
+}
+
+func (r *sessionRecorder) writeEvent(kind, data string) {
+	line, err := json.Marshal([]any{r.now().Sub(r.start).Seconds(), kind, data})
+	if err == nil {
+		_, err = fmt.Fprintf(r.w, "%s\n", line)
+	}
+	if err != nil && r.writeErr == nil {
+		r.writeErr = err
+	}
+}
+
+// resize records a change of terminal size
+func (r *sessionRecorder) resize(width, height int) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.writeEvent(castResize, fmt.Sprintf("%dx%d", width, height))
+}
+
+// output returns a writer recording session output
+func (r *sessionRecorder) output() io.Writer {
+	return recorderStream{r, castOutput}
+}
+
+// input returns a writer recording keyboard input, or discarding it unless
+// input recording was asked for
+func (r *sessionRecorder) input() io.Writer {
+	if !r.recordInput {
+		return io.Discard
+	}
+	return recorderStream{r, castInput}
+}
+
+// Close flushes held-back bytes and closes the file. It reports the first
+// write failure, so a recording that stopped early is not mistaken for a
+// complete one.
+func (r *sessionRecorder) Close() error {
+	r.mu.Lock()
+	for kind, data := range r.partial {
+		if len(data) > 0 {
+			r.writeEvent(kind, string(data))
+		}
+	}
+	r.partial = map[string][]byte{}
+	err := r.writeErr
+	r.mu.Unlock()
+
+	if r.closer != nil {
+		if closeErr := r.closer.Close(); err == nil {
+			err = closeErr
+		}
+	}
+	return err
+}
+
+type recorderStream struct {
+	r    *sessionRecorder
+	kind string
+}
+
+func (s recorderStream) Write(p []byte) (int, error) {
+	s.r.event(s.kind, p)
+	return len(p), nil
+}
+
+// playRecording writes the output events of an asciicast v2 recording to w
+// in real time, divided by speed. Pauses are capped at idleLimit when it is
+// positive.
+func playRecording(w io.Writer, recording io.Reader, speed float64, idleLimit time.Duration, sleep func(time.Duration)) error {
+	if speed <= 0 {
+		return fmt.Errorf("speed must be positive, got %v", speed)
+	}
+
+	scanner := bufio.NewScanner(recording)
+	scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+
+	if !scanner.Scan() {
+		if err := scanner.Err(); err != nil {
+			return err
+		}
+		return fmt.Errorf("empty recording")
+	}
+	var header castHeader
+	if err := json.Unmarshal(scanner.Bytes(), &header); err != nil {
+		return fmt.Errorf("invalid recording header: %w", err)
+	}
+	if header.Version != 2 {
+		return fmt.Errorf("unsupported asciicast version %d", header.Version)
+	}
+
+	var last float64
+	for line := 2; scanner.Scan(); line++ {
+		var (
+			event   []json.RawMessage
+			elapsed float64
+			kind    string
+			data    string
+		)
+		if err := json.Unmarshal(scanner.Bytes(), &event); err != nil || len(event) != 3 {
+			return fmt.Errorf("invalid event on line %d", line)
+		}
+		if json.Unmarshal(event[0], &elapsed) != nil || json.Unmarshal(event[1], &kind) != nil || json.Unmarshal(event[2], &data) != nil {
+			return fmt.Errorf("invalid event on line %d", line)
+		}
+		if kind != castOutput {
+			continue
+		}
+
+		pause := time.Duration((elapsed - last) / speed * float64(time.Second))
+		if idleLimit > 0 && pause > idleLimit {
+			pause = idleLimit
+		}
+		if pause > 0 {
+			sleep(pause)
+		}
+		last = elapsed
+
+		if _, err := io.WriteString(w, data); err != nil {
+			return err
+		}
+	}
+	return scanner.Err()
+}
+
+func handleRecordingPlay(path string, speed float64, idleLimit time.Duration) {
+	file, err := os.Open(path)
+	if err != nil {
+		log.Fatalf("Failed to open recording: %v", err)
+	}
+	defer file.Close()
+
+	if err := playRecording(os.Stdout, file, speed, idleLimit, time.Sleep); err != nil {
+		log.Fatalf("Failed to play recording: %v", err)
+	}
+}
@@ -0,0 +1,156 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+)
+
+// fakeClock returns start, then advances by step on every call
+func fakeClock(start time.Time, step time.Duration) func() time.Time {
+	now := start.Add(-step)
+	return func() time.Time {
+		now = now.Add(step)
+		return now
+	}
+}
+
+func TestSessionRecorder(t *testing.T) {
+	var buf bytes.Buffer
+	start := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+
+	rec, err := newSessionRecorder(&buf, castHeader{Width: 120, Height: 40, Title: "web-1"}, false, fakeClock(start, 500*time.Millisecond))
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	rec.output().Write([]byte("$ ls\r\n"))
+	rec.input().Write([]byte("secret\r"))
+	// "é" split across two writes
+	rec.output().Write([]byte{'c', 'a', 'f', 0xc3})
+	rec.output().Write([]byte{0xa9, '\n'})
+	rec.resize(100, 30)
+	if err := rec.Close(); err != nil {
+		t.Fatalf("Expected no error closing, got %v", err)
+	}
+
+	scanner := bufio.NewScanner(&buf)
+	scanner.Scan()
+	var header castHeader
+	if err := json.Unmarshal(scanner.Bytes(), &header); err != nil {
+		t.Fatalf("Expected a JSON header, got %v", err)
+	}
+	if header.Version != 2 || header.Width != 120 || header.Height != 40 || header.Timestamp != start.Unix() {
+		t.Errorf("Expected a v2 header for 120x40 at %d, got %+v", start.Unix(), header)
+	}
+
+	var events [][]any
+	for scanner.Scan() {
+		var event []any
+		if err := json.Unmarshal(scanner.Bytes(), &event); err != nil {
+			t.Fatalf("Expected a JSON event, got %v", err)
+		}
+		events = append(events, event)
+	}
+
+	expected := [][]any{
+		{0.5, "o", "$ ls\r\n"},
+		{1.0, "o", "caf"},
+		{1.5, "o", "é\n"},
+		{2.0, "r", "100x30"},
+	}
+	if len(events) != len(expected) {
+		t.Fatalf("Expected %d events without input, got %v", len(expected), events)
+	}
+	for i := range expected {
+		for j := range expected[i] {
+			if events[i][j] != expected[i][j] {
+				t.Errorf("Expected event %d to be %v, got %v", i, expected[i], events[i])
+				break
+			}
+		}
+	}
+}
+
+func TestSessionRecorderInput(t *testing.T) {
+	var buf bytes.Buffer
+	rec, err := newSessionRecorder(&buf, castHeader{Width: 80, Height: 24}, true, fakeClock(time.Now(), time.Second))
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	rec.input().Write([]byte("ls\r"))
+	rec.Close()
+
+	if !strings.Contains(buf.String(), `[1,"i","ls\r"]`) {
+		t.Errorf("Expected an input event, got %s", buf.String())
+	}
+}
+
+func TestPlayRecording(t *testing.T) {
+	recording := `{"version":2,"width":80,"height":24,"timestamp":1704164645}
+[0.5,"o","hello "]
+[1.0,"i","x"]
+[2.0,"o","world"]
+[12.0,"o","!"]
+`
+
+	tests := []struct {
+		name      string
+		speed     float64
+		idleLimit time.Duration
+		pauses    []time.Duration
+	}{
+		{"real time", 1, 0, []time.Duration{500 * time.Millisecond, 1500 * time.Millisecond, 10 * time.Second}},
+		{"double speed", 2, 0, []time.Duration{250 * time.Millisecond, 750 * time.Millisecond, 5 * time.Second}},
+		{"idle limit", 1, 2 * time.Second, []time.Duration{500 * time.Millisecond, 1500 * time.Millisecond, 2 * time.Second}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var out bytes.Buffer
+			var pauses []time.Duration
+			sleep := func(d time.Duration) { pauses = append(pauses, d) }
+
+			if err := playRecording(&out, strings.NewReader(recording), tt.speed, tt.idleLimit, sleep); err != nil {
+				t.Fatalf("Expected no error, got %v", err)
+			}
+			if out.String() != "hello world!" {
+				t.Errorf("Expected only the output events, got %q", out.String())
+			}
+			if len(pauses) != len(tt.pauses) {
+				t.Fatalf("Expected pauses %v, got %v", tt.pauses, pauses)
+			}
+			for i := range pauses {
+				if pauses[i] != tt.pauses[i] {
+					t.Errorf("Expected pauses %v, got %v", tt.pauses, pauses)
+					break
+				}
+			}
+		})
+	}
+}
+
+func TestPlayRecordingErrors(t *testing.T) {
+	tests := []struct {
+		name      string
+		recording string
+		speed     float64
+	}{
+		{"empty", "", 1},
+		{"not json", "asciinema\n", 1},
+		{"version 1", `{"version":1}` + "\n", 1},
+		{"bad event", `{"version":2}` + "\n[1,\"o\"]\n", 1},
+		{"zero speed", `{"version":2}` + "\n", 0},
+	}
+
+	for _, tt := range tests {
+		var out bytes.Buffer
+		if err := playRecording(&out, strings.NewReader(tt.recording), tt.speed, 0, func(time.Duration) {}); err == nil {
+			t.Errorf("Expected an error for %s", tt.name)
+		}
+	}
+}
@@ -0,0 +1,63 @@
+//go:build !windows
+
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"os/signal"
+	"syscall"
+
+	"github.com/creack/pty"
+	"golang.org/x/term"
+)
+
+// pluginRecordingSupported reports whether sessions run with
+// session-manager-plugin can be recorded on this platform
+const pluginRecordingSupported = true
+
+// runRecorded runs session-manager-plugin's cmd on a pseudo-terminal,
+// relaying it to and from ours and recording the session. The plugin reads the
+// window size from its stdout and switches its stdin to raw mode, so both
+// must be a terminal.
+func runRecorded(cmd *exec.Cmd, rec *sessionRecorder) error {
+	ptmx, err := pty.Start(cmd)
+	if err != nil {
+		return fmt.Errorf("failed to start a pseudo-terminal: %w", err)
+	}
+	defer ptmx.Close()
+
+	// Keep the pseudo-terminal the size of ours
+	_ = pty.InheritSize(os.Stdin, ptmx)
+	winch := make(chan os.Signal, 1)
+	signal.Notify(winch, syscall.SIGWINCH)
+	defer func() {
+		signal.Stop(winch)
+		close(winch)
+	}()
+	go func() {
+		for range winch {
+			if err := pty.InheritSize(os.Stdin, ptmx); err == nil {
+				rec.resize(terminalSize())
+			}
+		}
+	}()
+
+	// Keys go to the remote shell as typed, including Ctrl-C
+	if fd := int(os.Stdin.Fd()); term.IsTerminal(fd) {
+		state, err := term.MakeRaw(fd)
+		if err != nil {
+			return fmt.Errorf("failed to set the terminal to raw mode: %w", err)
+		}
+		defer term.Restore(fd, state)
+	}
+
+	go io.Copy(ptmx, io.TeeReader(os.Stdin, rec.input()))
+
+	// Reading fails once the plugin exits and the pseudo-terminal closes
+	_, _ = io.Copy(io.MultiWriter(os.Stdout, rec.output()), ptmx)
+
+	return cmd.Wait()
+}
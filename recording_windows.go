@@ -0,0 +1,16 @@
+//go:build windows
+
+package main
+
+import (
+	"errors"
+	"os/exec"
+)
+
+// pluginRecordingSupported reports whether sessions run with
+// session-manager-plugin can be recorded on this platform
+const pluginRecordingSupported = false
+
+func runRecorded(cmd *exec.Cmd, rec *sessionRecorder) error {
+	return errors.New("sessions run with session-manager-plugin can't be recorded on Windows")
+}
@@ -0,0 +1,76 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"sort"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ec2"
+)
+
+// regionConcurrency is the maximum number of regions listed at the same time
+const regionConcurrency = 8
+
+// multiRegion reports whether listings may span more than one region, in
+// which case the region is shown next to every instance
+func multiRegion() bool {
+	return allRegions || len(regionList) > 1
+}
+
+// regionConfig returns a copy of cfg that targets region. An empty region
+// leaves cfg unchanged.
+func regionConfig(cfg aws.Config, region string) aws.Config {
+	if region != "" {
+		cfg = cfg.Copy()
+		cfg.Region = region
+	}
+	return cfg
+}
+
+// targetRegions returns the regions to list in acct: every enabled region
+// with --all-regions, the --regions list, or just the configured region.
+// With --offline, --all-regions means every region with a cached listing of
+// kind and filters; kind is empty for listings that aren't cached.
+func targetRegions(ctx context.Context, acct awsAccount, kind string, filters []string) ([]string, error) {
+	cfg := acct.Config
+	switch {
+	case allRegions && offline && kind != "":
+		return cachedRegions(kind, acct.Profile, filters)
+	case allRegions:
+		return enabledRegions(ctx, cfg)
+	case len(regionList) > 0:
+		seen := make(map[string]bool)
+		var regions []string
+		for _, r := range regionList {
+			if r != "" && !seen[r] {
+				seen[r] = true
+				regions = append(regions, r)
+			}
+		}
+		return regions, nil
+	default:
+		return []string{cfg.Region}, nil
+	}
+}
+
+// enabledRegions lists the regions enabled for the account
+func enabledRegions(ctx context.Context, cfg aws.Config) ([]string, error) {
+	if cfg.Region == "" {
+		return nil, fmt.Errorf("a region is needed to look up the enabled regions; set --region or AWS_REGION")
+	}
+
+	ec2Client := ec2.NewFromConfig(cfg)
+	output, err := ec2Client.DescribeRegions(ctx, &ec2.DescribeRegionsInput{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to describe regions: %w", err)
+	}
+
+	var regions []string
+	for _, r := range output.Regions {
+		regions = append(regions, aws.ToString(r.RegionName))
+	}
+	sort.Strings(regions)
+
+	return regions, nil
+}
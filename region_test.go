@@ -0,0 +1,65 @@
+package main
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+)
+
+func TestTargetRegions(t *testing.T) {
+	defer func() { regionList, allRegions, offline = nil, false, false }()
+	t.Setenv("HOME", t.TempDir())
+
+	acct := awsAccount{Profile: "prod", Config: aws.Config{Region: "us-east-1"}}
+
+	regionList = nil
+	regions, err := targetRegions(context.Background(), acct, cacheKindEC2, nil)
+	if err != nil || !reflect.DeepEqual(regions, []string{"us-east-1"}) {
+		t.Errorf("Expected only the configured region, got %v, %v", regions, err)
+	}
+
+	regionList = []string{"eu-west-1", "us-west-2", "eu-west-1", ""}
+	regions, err = targetRegions(context.Background(), acct, cacheKindEC2, nil)
+	if err != nil || !reflect.DeepEqual(regions, []string{"eu-west-1", "us-west-2"}) {
+		t.Errorf("Expected the --regions list without duplicates, got %v, %v", regions, err)
+	}
+
+	// Offline, every region comes from the cache without asking AWS
+	regionList, allRegions, offline = nil, true, true
+	if _, err := targetRegions(context.Background(), acct, cacheKindEC2, nil); err == nil {
+		t.Errorf("Expected an error offline with an empty cache")
+	}
+
+	for _, path := range []string{
+		cachePath(cacheKindEC2, "prod", "us-west-2", nil),
+		cachePath(cacheKindEC2, "prod", "eu-west-1", nil),
+		cachePath(cacheKindEC2, "dev", "ap-south-1", nil),
+		cachePath(cacheKindEC2, "prod", "sa-east-1", []string{"tag:Env=prod"}),
+		cachePath(cacheKindRDS, "prod", "ca-central-1", nil),
+	} {
+		if err := writeCache(path, []Instance{}); err != nil {
+			t.Fatalf("Expected the cache to be written, got %v", err)
+		}
+	}
+
+	regions, err = targetRegions(context.Background(), acct, cacheKindEC2, nil)
+	if err != nil || !reflect.DeepEqual(regions, []string{"eu-west-1", "us-west-2"}) {
+		t.Errorf("Expected the regions cached for this listing, got %v, %v", regions, err)
+	}
+}
+
+func TestRegionConfig(t *testing.T) {
+	cfg := aws.Config{Region: "us-east-1"}
+
+	if got := regionConfig(cfg, "eu-west-1"); got.Region != "eu-west-1" {
+		t.Errorf("Expected eu-west-1, got %s", got.Region)
+	}
+	if got := regionConfig(cfg, ""); got.Region != "us-east-1" {
+		t.Errorf("Expected an empty region to keep us-east-1, got %s", got.Region)
+	}
+	if cfg.Region != "us-east-1" {
+		t.Errorf("Expected the original config to be left alone, got %s", cfg.Region)
+	}
+}
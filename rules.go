@@ -0,0 +1,208 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path"
+	"regexp"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/sts"
+)
+
+// ShellRule sets the session settings for the instances it matches. Fields
+// left empty keep the platform default from the linux/windows entries.
+type ShellRule struct {
+	Match       RuleMatch `yaml:"match"`
+	ShellConfig `yaml:",inline"`
+}
+
+// RuleMatch selects instances. Every condition that is set must match; a
+// rule without conditions matches every instance.
+type RuleMatch struct {
+	// Tags maps tag keys to glob patterns, e.g. {Env: prod, Team: "data-*"}
+	Tags map[string]string `yaml:"tags"`
+	// Name is a glob pattern for the Name tag
+	Name string `yaml:"name"`
+	// Platform is a glob pattern, ignoring case, for the EC2 platform details
+	// ("Linux/UNIX", "Red Hat Enterprise Linux") or the SSM platform name
+	// ("Alpine Linux", "Ubuntu")
+	Platform string `yaml:"platform"`
+	// AMI is an image ID or glob pattern
+	AMI string `yaml:"ami"`
+	// Account is an account ID or profile name
+	Account string `yaml:"account"`
+}
+
+// matches reports whether instance meets every condition of m. Invalid
+// patterns never match.
+func (m RuleMatch) matches(instance Instance) bool {
+	for key, pattern := range m.Tags {
+		value, ok := instance.Tags[key]
+		if !ok || !globMatch(pattern, value) {
+			return false
+		}
+	}
+	if m.Name != "" && !globMatch(m.Name, instance.Name) {
+		return false
+	}
+	if m.Platform != "" {
+		pattern := strings.ToLower(m.Platform)
+		if !globMatch(pattern, strings.ToLower(instance.PlatformDetails)) && !globMatch(pattern, strings.ToLower(instance.PlatformName)) {
+			return false
+		}
+	}
+	if m.AMI != "" && !globMatch(m.AMI, instance.ImageID) {
+		return false
+	}
+	if m.Account != "" && m.Account != instance.AccountID && m.Account != instance.Profile {
+		return false
+	}
+	return true
+}
+
+// validate checks the glob patterns of m
+func (m RuleMatch) validate() error {
+	patterns := []string{m.Name, m.Platform, m.AMI}
+	for _, p := range m.Tags {
+		patterns = append(patterns, p)
+	}
+	for _, p := range patterns {
+		if _, err := path.Match(p, ""); err != nil {
+			return fmt.Errorf("invalid pattern %q: %w", p, err)
+		}
+	}
+	return nil
+}
+
+// globMatch reports whether value matches the path.Match pattern, except
+// that * and ? also match "/": platform details ("Linux/UNIX") and tag
+// values aren't paths. "/" is swapped for a byte tags can't contain, which
+// path.Match treats like any other.
+func globMatch(pattern, value string) bool {
+	matched, err := path.Match(strings.ReplaceAll(pattern, "/", "\x00"), strings.ReplaceAll(value, "/", "\x00"))
+	return err == nil && matched
+}
+
+// validateRules checks the patterns of every rule
+func validateRules(rules []ShellRule) error {
+	var errs []error
+	for i, rule := range rules {
+		if err := rule.Match.validate(); err != nil {
+			errs = append(errs, fmt.Errorf("rule %d: %w", i+1, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// shellConfigFor returns the session settings for an instance: its
+// platform's default, overridden by the first rule that matches it
+func shellConfigFor(config Config, instance Instance) ShellConfig {
+	shell := config.Linux
+	if instance.Platform == "windows" {
+		shell = config.Windows
+	}
+
+	for _, rule := range config.Rules {
+		if rule.Match.matches(instance) {
+			return shell.override(rule.ShellConfig)
+		}
+	}
+	return shell
+}
+
+// override returns s with the fields set in o. Parameters belong to their
+// document, so a rule that sets a document replaces them too, and a rule
+// that sets a shell replaces the preference list.
+func (s ShellConfig) override(o ShellConfig) ShellConfig {
+	if o.Shell != "" {
+		s.Shell = o.Shell
+		s.Shells = o.Shells
+	} else if o.Shells != nil {
+		s.Shells = o.Shells
+	}
+	if o.Document != "" {
+		s.Document = o.Document
+		s.Parameters = o.Parameters
+	} else if o.Parameters != nil {
+		s.Parameters = o.Parameters
+	}
+	if o.RunAs != "" {
+		s.RunAs = o.RunAs
+	}
+	if o.WorkingDirectory != "" {
+		s.WorkingDirectory = o.WorkingDirectory
+	}
+	return s
+}
+
+// rulesMatchAccounts reports whether any rule matches on the account
+func rulesMatchAccounts(rules []ShellRule) bool {
+	for _, rule := range rules {
+		if rule.Match.Account != "" {
+			return true
+		}
+	}
+	return false
+}
+
+// fillAccountID looks up the account of an instance listed without one, so
+// rules can match on it
+func fillAccountID(ctx context.Context, cfg aws.Config, instance *Instance) {
+	if instance.AccountID != "" {
+		return
+	}
+	output, err := sts.NewFromConfig(cfg).GetCallerIdentity(ctx, &sts.GetCallerIdentityInput{})
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: could not look up the account to match rules: %v\n", err)
+		return
+	}
+	instance.AccountID = aws.ToString(output.Account)
+}
+
+// shellSafe matches arguments that need no quoting in sh
+var shellSafe = regexp.MustCompile(`^[A-Za-z0-9_@%+=:,./-]+$`)
+
+// shellQuote quotes s for sh
+func shellQuote(s string) string {
+	if shellSafe.MatchString(s) {
+		return s
+	}
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
+
+// shellCommand returns the command line that starts shell as runAs (unless
+// empty) in dir (unless empty) on an instance of platform
+func shellCommand(platform, shell, runAs, dir string) (string, error) {
+	if platform == "windows" {
+		if runAs != "" {
+			return "", errors.New("running as another user is not supported on Windows instances")
+		}
+		if dir == "" {
+			return shell, nil
+		}
+		lower := strings.ToLower(shell)
+		switch {
+		case strings.Contains(lower, "powershell") || strings.Contains(lower, "pwsh"):
+			return fmt.Sprintf(`%s -NoExit -Command "Set-Location -LiteralPath '%s'"`, shell, strings.ReplaceAll(dir, "'", "''")), nil
+		case strings.Contains(lower, "cmd"):
+			return fmt.Sprintf(`%s /K cd /d "%s"`, shell, dir), nil
+		}
+		return "", fmt.Errorf("can't set the working directory for shell %s", shell)
+	}
+
+	command := shell
+	if dir != "" {
+		command = fmt.Sprintf("cd %s && exec %s", shellQuote(dir), shell)
+	}
+	if runAs == "" {
+		return command, nil
+	}
+	if dir != "" {
+		return fmt.Sprintf("sudo -iu %s sh -c %s", shellQuote(runAs), shellQuote(command)), nil
+	}
+	return fmt.Sprintf("sudo -iu %s %s", shellQuote(runAs), shell), nil
+}
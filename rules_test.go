@@ -0,0 +1,181 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestRuleMatch(t *testing.T) {
+	alpine := Instance{
+		ID:              "i-0123456789abcdef0",
+		Name:            "edge-proxy-1",
+		Profile:         "production",
+		AccountID:       "123456789012",
+		Platform:        "linux",
+		ImageID:         "ami-0abc123",
+		PlatformDetails: "Linux/UNIX",
+		PlatformName:    "Alpine Linux",
+		Tags:            map[string]string{"Name": "edge-proxy-1", "Env": "prod", "Team": "data-eng", "Service": "payments/api"},
+	}
+
+	tests := []struct {
+		name  string
+		match RuleMatch
+		want  bool
+	}{
+		{"empty match", RuleMatch{}, true},
+		{"tag", RuleMatch{Tags: map[string]string{"Env": "prod"}}, true},
+		{"tag glob", RuleMatch{Tags: map[string]string{"Team": "data-*"}}, true},
+		{"every tag must match", RuleMatch{Tags: map[string]string{"Env": "prod", "Team": "web"}}, false},
+		{"missing tag", RuleMatch{Tags: map[string]string{"Owner": "*"}}, false},
+		{"tag glob across a slash", RuleMatch{Tags: map[string]string{"Service": "payments*"}}, true},
+		{"tag with a slash", RuleMatch{Tags: map[string]string{"Service": "*/api"}}, true},
+		{"name glob", RuleMatch{Name: "edge-*"}, true},
+		{"name mismatch", RuleMatch{Name: "web-*"}, false},
+		{"SSM platform name ignoring case", RuleMatch{Platform: "alpine*"}, true},
+		{"EC2 platform details", RuleMatch{Platform: "Linux/UNIX"}, true},
+		{"platform details prefix", RuleMatch{Platform: "linux*"}, true},
+		{"platform details suffix", RuleMatch{Platform: "*unix"}, true},
+		{"single character across a slash", RuleMatch{Platform: "linux?unix"}, true},
+		{"platform mismatch", RuleMatch{Platform: "Ubuntu"}, false},
+		{"AMI", RuleMatch{AMI: "ami-0abc123"}, true},
+		{"AMI glob", RuleMatch{AMI: "ami-0def*"}, false},
+		{"account ID", RuleMatch{Account: "123456789012"}, true},
+		{"profile", RuleMatch{Account: "production"}, true},
+		{"account mismatch", RuleMatch{Account: "staging"}, false},
+		{"all conditions", RuleMatch{Name: "edge-*", Platform: "Alpine*", Account: "production"}, true},
+		{"invalid pattern", RuleMatch{Name: "[edge"}, false},
+	}
+
+	for _, tt := range tests {
+		if got := tt.match.matches(alpine); got != tt.want {
+			t.Errorf("%s: expected %v, got %v", tt.name, tt.want, got)
+		}
+	}
+}
+
+func TestValidateRules(t *testing.T) {
+	valid := []ShellRule{{Match: RuleMatch{Name: "web-*", Tags: map[string]string{"Env": "prod"}}}}
+	if err := validateRules(valid); err != nil {
+		t.Errorf("Expected no error, got %v", err)
+	}
+
+	invalid := []ShellRule{valid[0], {Match: RuleMatch{Tags: map[string]string{"Env": "[prod"}}}}
+	if err := validateRules(invalid); err == nil {
+		t.Errorf("Expected an error for an invalid tag pattern")
+	}
+}
+
+func TestShellConfigFor(t *testing.T) {
+	config := Config{
+		Linux:   ShellConfig{Shell: "/bin/bash"},
+		Windows: ShellConfig{Shell: "powershell.exe"},
+		Rules: []ShellRule{
+			{Match: RuleMatch{Platform: "Alpine*"}, ShellConfig: ShellConfig{Shell: "/bin/sh"}},
+			{
+				Match: RuleMatch{Tags: map[string]string{"Env": "prod"}},
+				ShellConfig: ShellConfig{
+					Document:   "Company-RestrictedShell",
+					Parameters: map[string]string{"ticket": "{{.Instance.Tags.Ticket}}"},
+					RunAs:      "app",
+				},
+			},
+			{Match: RuleMatch{Name: "batch-*"}, ShellConfig: ShellConfig{WorkingDirectory: "/srv"}},
+		},
+	}
+
+	tests := []struct {
+		name     string
+		instance Instance
+		want     ShellConfig
+	}{
+		{
+			name:     "first match wins",
+			instance: Instance{Name: "edge-1", Platform: "linux", PlatformName: "Alpine Linux", Tags: map[string]string{"Env": "prod"}},
+			want:     ShellConfig{Shell: "/bin/sh"},
+		},
+		{
+			name:     "rule keeps the default shell",
+			instance: Instance{Name: "web-1", Platform: "linux", Tags: map[string]string{"Env": "prod"}},
+			want: ShellConfig{
+				Shell:      "/bin/bash",
+				Document:   "Company-RestrictedShell",
+				Parameters: map[string]string{"ticket": "{{.Instance.Tags.Ticket}}"},
+				RunAs:      "app",
+			},
+		},
+		{
+			name:     "windows default",
+			instance: Instance{Platform: "windows"},
+			want:     ShellConfig{Shell: "powershell.exe"},
+		},
+		{
+			name:     "later rule",
+			instance: Instance{Name: "batch-1", Platform: "linux"},
+			want:     ShellConfig{Shell: "/bin/bash", WorkingDirectory: "/srv"},
+		},
+	}
+
+	for _, tt := range tests {
+		if got := shellConfigFor(config, tt.instance); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: expected %+v, got %+v", tt.name, tt.want, got)
+		}
+	}
+}
+
+func TestShellConfigOverride(t *testing.T) {
+	defaults := ShellConfig{Shell: "/bin/bash", Document: "Default-Doc", Parameters: map[string]string{"a": "1"}}
+
+	got := defaults.override(ShellConfig{Document: "Other-Doc"})
+	if got.Document != "Other-Doc" || got.Parameters != nil {
+		t.Errorf("Expected a new document to drop the default parameters, got %+v", got)
+	}
+
+	got = defaults.override(ShellConfig{Parameters: map[string]string{"b": "2"}})
+	if got.Document != "Default-Doc" || !reflect.DeepEqual(got.Parameters, map[string]string{"b": "2"}) {
+		t.Errorf("Expected new parameters for the default document, got %+v", got)
+	}
+
+	detecting := ShellConfig{Shell: "/bin/bash", Shells: []string{"zsh", "bash"}}
+
+	got = detecting.override(ShellConfig{Shell: "/bin/sh"})
+	if got.Shell != "/bin/sh" || got.Shells != nil {
+		t.Errorf("Expected a rule's shell to replace the preference list, got %+v", got)
+	}
+
+	got = detecting.override(ShellConfig{Shells: []string{"sh"}})
+	if got.Shell != "/bin/bash" || !reflect.DeepEqual(got.Shells, []string{"sh"}) {
+		t.Errorf("Expected a rule's preference list with the default fallback, got %+v", got)
+	}
+}
+
+func TestShellCommand(t *testing.T) {
+	tests := []struct {
+		platform, shell, runAs, dir string
+		want                        string
+		wantErr                     bool
+	}{
+		{"linux", "/bin/bash", "", "", "/bin/bash", false},
+		{"linux", "/bin/bash", "", "/srv/app", "cd /srv/app && exec /bin/bash", false},
+		{"linux", "/bin/bash", "", "/srv/my app", "cd '/srv/my app' && exec /bin/bash", false},
+		{"linux", "/bin/bash", "deploy", "", "sudo -iu deploy /bin/bash", false},
+		{"linux", "/bin/bash", "deploy", "/srv/app", "sudo -iu deploy sh -c 'cd /srv/app && exec /bin/bash'", false},
+		{"linux", "/bin/sh", "o'brien", "", `sudo -iu 'o'\''brien' /bin/sh`, false},
+		{"windows", "powershell.exe", "", "", "powershell.exe", false},
+		{"windows", "powershell.exe", "", `C:\it's`, `powershell.exe -NoExit -Command "Set-Location -LiteralPath 'C:\it''s'"`, false},
+		{"windows", "cmd.exe", "", `C:\apps`, `cmd.exe /K cd /d "C:\apps"`, false},
+		{"windows", "powershell.exe", "Administrator", "", "", true},
+		{"windows", "bash.exe", "", `C:\apps`, "", true},
+	}
+
+	for _, tt := range tests {
+		got, err := shellCommand(tt.platform, tt.shell, tt.runAs, tt.dir)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("shellCommand(%q, %q, %q, %q): expected error %v, got %v", tt.platform, tt.shell, tt.runAs, tt.dir, tt.wantErr, err)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("shellCommand(%q, %q, %q, %q): expected %q, got %q", tt.platform, tt.shell, tt.runAs, tt.dir, tt.want, got)
+		}
+	}
+}
@@ -0,0 +1,142 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"os/exec"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ssm"
+)
+
+// terminateTimeout bounds the TerminateSession call made when a session ends
+const terminateTimeout = 10 * time.Second
+
+// pluginInstallURL documents how to install session-manager-plugin
+const pluginInstallURL = "https://docs.aws.amazon.com/systems-manager/latest/userguide/session-manager-working-with-install-plugin.html"
+
+// pluginStopDelay is how long session-manager-plugin has to exit after it is
+// asked to stop before it is killed
+const pluginStopDelay = 5 * time.Second
+
+// sessionSignals end a session, which is then terminated, instead of killing
+// the process
+var sessionSignals = []os.Signal{os.Interrupt, syscall.SIGTERM, syscall.SIGHUP}
+
+var (
+	// errInterrupted is the cause of a session context ended by a signal
+	errInterrupted = errors.New("interrupted")
+	// errMaxDuration is the cause of a session context that reached --max-duration
+	errMaxDuration = errors.New("the session reached its maximum duration")
+)
+
+// sessionExitError is returned when the session's shell, or
+// session-manager-plugin, exits unsuccessfully, so the tool can exit with the
+// same code
+type sessionExitError struct {
+	code int
+	err  error
+}
+
+func (e *sessionExitError) Error() string {
+	if e.err == nil {
+		return fmt.Sprintf("the session exited with code %d", e.code)
+	}
+	return fmt.Sprintf("session-manager-plugin exited with code %d", e.code)
+}
+
+func (e *sessionExitError) Unwrap() error {
+	return e.err
+}
+
+// sessionContext returns the context for one session. It is cancelled when
+// the process is interrupted or terminated, with errInterrupted as its cause,
+// or once maxDuration has passed (unless it is zero), with errMaxDuration.
+func sessionContext(ctx context.Context, maxDuration time.Duration) (context.Context, context.CancelFunc) {
+	ctx, cancel := context.WithCancelCause(ctx)
+
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, sessionSignals...)
+	go func(done <-chan struct{}) {
+		select {
+		case sig := <-signals:
+			cancel(fmt.Errorf("%w by %s", errInterrupted, sig))
+		case <-done:
+		}
+	}(ctx.Done())
+
+	stop := func() {
+		signal.Stop(signals)
+		cancel(nil)
+	}
+
+	if maxDuration <= 0 {
+		return ctx, stop
+	}
+
+	ctx, cancelTimeout := context.WithTimeoutCause(ctx, maxDuration, errMaxDuration)
+	return ctx, func() {
+		cancelTimeout()
+		stop()
+	}
+}
+
+// terminateSession ends a session on the service side, so it doesn't linger
+// as Connected after the client has gone. The session's context is usually
+// done by then, so the call gets its own deadline.
+func terminateSession(ctx context.Context, client *ssm.Client, sessionID string) {
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), terminateTimeout)
+	defer cancel()
+
+	_, err := client.TerminateSession(ctx, &ssm.TerminateSessionInput{
+		SessionId: aws.String(sessionID),
+	})
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: failed to terminate session %s: %v\n", sessionID, err)
+	}
+}
+
+// pluginInstalled reports whether session-manager-plugin is on the PATH
+func pluginInstalled() bool {
+	_, err := exec.LookPath("session-manager-plugin")
+	return err == nil
+}
+
+// stopPlugin asks session-manager-plugin to exit, killing it where signals
+// are not supported (Windows)
+func stopPlugin(cmd *exec.Cmd) error {
+	if err := cmd.Process.Signal(syscall.SIGTERM); err != nil {
+		return cmd.Process.Kill()
+	}
+	return nil
+}
+
+// pluginError converts an unsuccessful exit of session-manager-plugin into a
+// sessionExitError carrying its exit code
+func pluginError(err error) error {
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) && exitErr.ExitCode() > 0 {
+		return &sessionExitError{code: exitErr.ExitCode(), err: err}
+	}
+	return fmt.Errorf("session-manager-plugin error: %w", err)
+}
+
+// fatalSession logs a failed session and exits, with the session's exit code
+// when it exited unsuccessfully and 1 otherwise
+func fatalSession(format string, err error) {
+	var exitErr *sessionExitError
+	if errors.As(err, &exitErr) {
+		// A shell's exit status is the user's own and isn't a failure to report
+		if exitErr.err != nil {
+			log.Printf(format, err)
+		}
+		os.Exit(exitErr.code)
+	}
+	log.Fatalf(format, err)
+}
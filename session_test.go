@@ -0,0 +1,237 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ssm"
+)
+
+func TestSessionContextMaxDuration(t *testing.T) {
+	ctx, stop := sessionContext(context.Background(), 20*time.Millisecond)
+	defer stop()
+
+	select {
+	case <-ctx.Done():
+	case <-time.After(5 * time.Second):
+		t.Fatalf("Expected the session context to end after its maximum duration")
+	}
+	if cause := context.Cause(ctx); !errors.Is(cause, errMaxDuration) {
+		t.Errorf("Expected errMaxDuration, got %v", cause)
+	}
+}
+
+func TestSessionContextSignal(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("signals can't be sent to the process on Windows")
+	}
+
+	ctx, stop := sessionContext(context.Background(), 0)
+	defer stop()
+
+	process, err := os.FindProcess(os.Getpid())
+	if err != nil {
+		t.Fatalf("Expected to find the test process, got %v", err)
+	}
+	if err := process.Signal(syscall.SIGHUP); err != nil {
+		t.Fatalf("Expected to send SIGHUP, got %v", err)
+	}
+
+	select {
+	case <-ctx.Done():
+	case <-time.After(5 * time.Second):
+		t.Fatalf("Expected the session context to end on SIGHUP")
+	}
+	if cause := context.Cause(ctx); !errors.Is(cause, errInterrupted) {
+		t.Errorf("Expected errInterrupted, got %v", cause)
+	}
+}
+
+func TestSessionContextStop(t *testing.T) {
+	ctx, stop := sessionContext(context.Background(), time.Hour)
+	stop()
+
+	if ctx.Err() == nil {
+		t.Fatalf("Expected the session context to be done after stop")
+	}
+	if cause := context.Cause(ctx); errors.Is(cause, errMaxDuration) || errors.Is(cause, errInterrupted) {
+		t.Errorf("Expected a plain cancellation, got %v", cause)
+	}
+}
+
+func TestPluginError(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("needs a POSIX shell")
+	}
+
+	tests := []struct {
+		name     string
+		err      error
+		wantCode int
+	}{
+		{"exit code", exec.Command("sh", "-c", "exit 3").Run(), 3},
+		{"not found", exec.Command("session-manager-plugin-that-does-not-exist").Run(), 0},
+	}
+
+	for _, tt := range tests {
+		err := pluginError(tt.err)
+
+		var exitErr *sessionExitError
+		switch {
+		case tt.wantCode == 0 && errors.As(err, &exitErr):
+			t.Errorf("%s: Expected no exit code, got %d", tt.name, exitErr.code)
+		case tt.wantCode != 0 && !errors.As(err, &exitErr):
+			t.Errorf("%s: Expected a sessionExitError, got %v", tt.name, err)
+		case tt.wantCode != 0 && exitErr.code != tt.wantCode:
+			t.Errorf("%s: Expected exit code %d, got %d", tt.name, tt.wantCode, exitErr.code)
+		}
+	}
+}
+
+func TestNativeSessionEndsWithContext(t *testing.T) {
+	port, err := freeLocalPort()
+	if err != nil {
+		t.Fatalf("Expected a free port, got %v", err)
+	}
+
+	url, agentDone := startFakeAgent(t, func(a *fakeAgent) {
+		if _, ok := a.acceptHandshake(sessionTypePort); !ok {
+			return
+		}
+		for {
+			if _, _, err := a.conn.ReadMessage(); err != nil {
+				return
+			}
+		}
+	})
+
+	session := &ssm.StartSessionOutput{StreamUrl: aws.String(url), TokenValue: aws.String("token")}
+	input := &ssm.StartSessionInput{Parameters: map[string][]string{"localPortNumber": {strconv.Itoa(port)}}}
+
+	ctx, cancel := context.WithCancelCause(context.Background())
+	result := make(chan error, 1)
+	go func() { result <- runNativeSession(ctx, session, input, "", nil) }()
+
+	time.Sleep(100 * time.Millisecond)
+	cancel(errInterrupted)
+
+	select {
+	case err := <-result:
+		if !errors.Is(err, errInterrupted) {
+			t.Errorf("Expected the session to end with errInterrupted, got %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatalf("Expected the session to end when its context was cancelled")
+	}
+	waitAgent(t, agentDone)
+}
+
+func TestRunSessionFallsBackToPluginForKMS(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("the fake session-manager-plugin is a shell script")
+	}
+
+	tests := []struct {
+		name       string
+		withPlugin bool
+	}{
+		{"plugin installed", true},
+		{"plugin missing", false},
+	}
+
+	for _, tt := range tests {
+		url, agentDone := startFakeAgent(t, func(a *fakeAgent) {
+			a.handshake(0, requestedClientAction{ActionType: actionKMSEncryption, ActionParameters: json.RawMessage(`{"KMSKeyId":"alias/ssm"}`)})
+			a.nextInput()
+		})
+
+		// The fake plugin records that it ran
+		dir := t.TempDir()
+		if tt.withPlugin {
+			script := "#!/bin/sh\necho \"$3\" > \"${0%/*}/ran\"\n"
+			if err := os.WriteFile(filepath.Join(dir, "session-manager-plugin"), []byte(script), 0o755); err != nil {
+				t.Fatalf("Expected the fake plugin to be written, got %v", err)
+			}
+		}
+		t.Setenv("PATH", dir)
+
+		started, terminated := 0, 0
+		cfg := fakeAWS(t, map[string]http.HandlerFunc{
+			"StartSession": func(w http.ResponseWriter, r *http.Request) {
+				started++
+				fmt.Fprintf(w, `{"SessionId": "s-%d", "StreamUrl": %q, "TokenValue": "token"}`, started, url)
+			},
+			"TerminateSession": func(w http.ResponseWriter, r *http.Request) {
+				terminated++
+				fmt.Fprint(w, `{}`)
+			},
+		})
+
+		err := runSession(context.Background(), cfg, &ssm.StartSessionInput{Target: aws.String("i-1")}, "", nil)
+		waitAgent(t, agentDone)
+
+		if !tt.withPlugin {
+			if !errors.Is(err, errKMSEncryption) || !strings.Contains(err.Error(), "install session-manager-plugin") {
+				t.Errorf("%s: expected a KMS error suggesting the plugin, got %v", tt.name, err)
+			}
+			if started != 1 || terminated != 1 {
+				t.Errorf("%s: expected one session, started %d and terminated %d", tt.name, started, terminated)
+			}
+			continue
+		}
+
+		if err != nil {
+			t.Errorf("%s: expected the plugin session to succeed, got %v", tt.name, err)
+		}
+		if started != 2 || terminated != 2 {
+			t.Errorf("%s: expected the session to be started again, started %d and terminated %d", tt.name, started, terminated)
+		}
+		if ran, err := os.ReadFile(filepath.Join(dir, "ran")); err != nil || strings.TrimSpace(string(ran)) != "StartSession" {
+			t.Errorf("%s: expected session-manager-plugin to run the session, got %q, %v", tt.name, ran, err)
+		}
+	}
+}
+
+func TestRunSessionUsesPluginForPortSessions(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("the fake session-manager-plugin is a shell script")
+	}
+
+	// The fake plugin records the session parameters it was given
+	dir := t.TempDir()
+	script := "#!/bin/sh\necho \"$5\" > \"${0%/*}/ran\"\n"
+	if err := os.WriteFile(filepath.Join(dir, "session-manager-plugin"), []byte(script), 0o755); err != nil {
+		t.Fatalf("Expected the fake plugin to be written, got %v", err)
+	}
+	t.Setenv("PATH", dir)
+
+	cfg := fakeAWS(t, map[string]http.HandlerFunc{
+		"StartSession": func(w http.ResponseWriter, r *http.Request) {
+			fmt.Fprint(w, `{"SessionId": "s-1", "StreamUrl": "wss://unused", "TokenValue": "token"}`)
+		},
+		"TerminateSession": func(w http.ResponseWriter, r *http.Request) {
+			fmt.Fprint(w, `{}`)
+		},
+	})
+
+	input := &ssm.StartSessionInput{Target: aws.String("i-1"), Parameters: map[string][]string{"localPortNumber": {"18080"}}}
+	if err := runSession(context.Background(), cfg, input, "", nil); err != nil {
+		t.Fatalf("Expected the plugin session to succeed, got %v", err)
+	}
+	if ran, err := os.ReadFile(filepath.Join(dir, "ran")); err != nil || !strings.Contains(string(ran), "18080") {
+		t.Errorf("Expected session-manager-plugin to run the port session, got %q, %v", ran, err)
+	}
+}
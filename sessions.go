@@ -0,0 +1,301 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/AlecAivazis/survey/v2"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ssm"
+	ssmtypes "github.com/aws/aws-sdk-go-v2/service/ssm/types"
+	"github.com/aws/aws-sdk-go-v2/service/sts"
+)
+
+// Session states accepted by "sessions list --state"
+const (
+	sessionStateActive  = string(ssmtypes.SessionStateActive)
+	sessionStateHistory = string(ssmtypes.SessionStateHistory)
+)
+
+// ssmSession is one Session Manager session, with its target's Name tag
+// when the target is a listed instance
+type ssmSession struct {
+	ID         string
+	Target     string
+	TargetName string
+	Owner      string
+	Status     string
+	Document   string
+	StartDate  time.Time
+	EndDate    time.Time
+	Profile    string
+	AccountID  string
+	Region     string
+}
+
+// parseSessionState matches a --state value case-insensitively
+func parseSessionState(state string) (string, error) {
+	for _, s := range []string{sessionStateActive, sessionStateHistory} {
+		if strings.EqualFold(state, s) {
+			return s, nil
+		}
+	}
+	return "", fmt.Errorf("unknown session state %q (want %s or %s)", state, sessionStateActive, sessionStateHistory)
+}
+
+// listSessions lists the sessions in state in every target region of every
+// account, newest first. With mine set, only the caller's own sessions are
+// listed.
+func listSessions(ctx context.Context, cfg aws.Config, state string, mine bool) ([]ssmSession, error) {
+	sessions, err := listAcrossAccounts(ctx, cfg, "sessions", "", nil, func(ctx context.Context, acct awsAccount) ([]ssmSession, error) {
+		input := &ssm.DescribeSessionsInput{State: ssmtypes.SessionState(state)}
+		if mine {
+			identity, err := sts.NewFromConfig(acct.Config).GetCallerIdentity(ctx, &sts.GetCallerIdentityInput{})
+			if err != nil {
+				return nil, fmt.Errorf("failed to look up the caller identity: %w", err)
+			}
+			input.Filters = []ssmtypes.SessionFilter{{
+				Key:   ssmtypes.SessionFilterKeyOwner,
+				Value: identity.Arn,
+			}}
+		}
+
+		var sessions []ssmSession
+		paginator := ssm.NewDescribeSessionsPaginator(ssm.NewFromConfig(acct.Config), input)
+		for paginator.HasMorePages() {
+			output, err := paginator.NextPage(ctx)
+			if err != nil {
+				return nil, fmt.Errorf("failed to describe sessions: %w", err)
+			}
+			for _, s := range output.Sessions {
+				sessions = append(sessions, ssmSession{
+					ID:        aws.ToString(s.SessionId),
+					Target:    aws.ToString(s.Target),
+					Owner:     aws.ToString(s.Owner),
+					Status:    string(s.Status),
+					Document:  aws.ToString(s.DocumentName),
+					StartDate: aws.ToTime(s.StartDate),
+					EndDate:   aws.ToTime(s.EndDate),
+					Profile:   acct.Profile,
+					AccountID: acct.AccountID,
+					Region:    acct.Config.Region,
+				})
+			}
+		}
+		return sessions, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	sort.SliceStable(sessions, func(i, j int) bool {
+		return sessions[i].StartDate.After(sessions[j].StartDate)
+	})
+	return sessions, nil
+}
+
+// nameSessionTargets fills in the Name tag of every session target found
+// among instances
+func nameSessionTargets(sessions []ssmSession, instances []Instance) {
+	names := make(map[string]string, len(instances))
+	for _, inst := range instances {
+		names[inst.ID] = inst.Name
+	}
+	for i := range sessions {
+		sessions[i].TargetName = names[sessions[i].Target]
+	}
+}
+
+// listNamedSessions lists sessions and joins them with the EC2 inventory.
+// The inventory only adds names, so failing to list it is just a warning.
+func listNamedSessions(ctx context.Context, cfg aws.Config, state string, mine bool) ([]ssmSession, error) {
+	sessions, err := listSessions(ctx, cfg, state, mine)
+	if err != nil {
+		return nil, err
+	}
+	if len(sessions) == 0 {
+		return sessions, nil
+	}
+
+	instances, err := listInstances(ctx, cfg)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: could not list instances to name session targets: %v\n", err)
+	}
+	nameSessionTargets(sessions, instances)
+	return sessions, nil
+}
+
+// sessionOwner shortens an owner ARN to the principal, e.g.
+// "assumed-role/Admin/alice" or "user/bob"
+func sessionOwner(owner string) string {
+	if i := strings.LastIndex(owner, ":"); i >= 0 {
+		return owner[i+1:]
+	}
+	return owner
+}
+
+func sessionTime(t time.Time) string {
+	if t.IsZero() {
+		return "-"
+	}
+	return t.Local().Format("2006-01-02 15:04")
+}
+
+// displaySessions prints sessions as a table. Ended sessions also show when
+// they ended.
+func displaySessions(out io.Writer, sessions []ssmSession, history bool) {
+	w := tabwriter.NewWriter(out, 0, 0, 3, ' ', 0)
+	header := "SESSION ID\tTARGET\tNAME\tOWNER\tSTATUS\tSTARTED"
+	if history {
+		header += "\tENDED"
+	}
+	fmt.Fprintln(w, header+"\tDOCUMENT"+locationColumns("ACCOUNT", "", "REGION"))
+
+	for _, s := range sessions {
+		row := []string{s.ID, orDash(s.Target), orDash(s.TargetName), orDash(sessionOwner(s.Owner)), s.Status, sessionTime(s.StartDate)}
+		if history {
+			row = append(row, sessionTime(s.EndDate))
+		}
+		row = append(row, orDash(s.Document))
+		fmt.Fprintln(w, strings.Join(row, "\t")+locationColumns(s.Profile, s.AccountID, s.Region))
+	}
+	w.Flush()
+}
+
+func handleSessionsList(ctx context.Context, cfg aws.Config, state string, mine bool) {
+	sessions, err := listNamedSessions(ctx, cfg, state, mine)
+	if err != nil {
+		log.Fatalf("Failed to list sessions: %v", err)
+	}
+
+	if len(sessions) == 0 {
+		fmt.Printf("No %s sessions found\n", strings.ToLower(state))
+		return
+	}
+	displaySessions(os.Stdout, sessions, state == sessionStateHistory)
+}
+
+// handleSessionsTerminate terminates the sessions with the given IDs, or
+// the ones picked from the active sessions when no IDs are given
+func handleSessionsTerminate(ctx context.Context, cfg aws.Config, ids []string, mine bool) {
+	var targets []ssmSession
+	switch {
+	case len(ids) == 0:
+		if !isInteractive() {
+			log.Fatalf("No session IDs given")
+		}
+		sessions, err := listNamedSessions(ctx, cfg, sessionStateActive, mine)
+		if err != nil {
+			log.Fatalf("Failed to list sessions: %v", err)
+		}
+		if len(sessions) == 0 {
+			fmt.Println("No active sessions found")
+			return
+		}
+		if targets, err = selectSessions(sessions); err != nil {
+			log.Fatalf("Failed to select sessions: %v", err)
+		}
+
+	case multiAccount() || multiRegion():
+		// Session IDs don't say which account and region they belong to
+		sessions, err := listSessions(ctx, cfg, sessionStateActive, false)
+		if err != nil {
+			log.Fatalf("Failed to list sessions: %v", err)
+		}
+		if targets, err = findSessions(sessions, ids); err != nil {
+			log.Fatalf("Failed to find sessions: %v", err)
+		}
+
+	default:
+		for _, id := range ids {
+			targets = append(targets, ssmSession{ID: id, Region: cfg.Region})
+		}
+	}
+
+	if err := terminateSessions(ctx, cfg, targets); err != nil {
+		log.Fatalf("Failed to terminate sessions: %v", err)
+	}
+}
+
+// findSessions returns the sessions with the given IDs, in ID order
+func findSessions(sessions []ssmSession, ids []string) ([]ssmSession, error) {
+	byID := make(map[string]ssmSession, len(sessions))
+	for _, s := range sessions {
+		byID[s.ID] = s
+	}
+
+	var found []ssmSession
+	var missing []string
+	for _, id := range ids {
+		s, ok := byID[id]
+		if !ok {
+			missing = append(missing, id)
+			continue
+		}
+		found = append(found, s)
+	}
+	if len(missing) > 0 {
+		return nil, fmt.Errorf("no active session %s", strings.Join(missing, ", "))
+	}
+	return found, nil
+}
+
+// selectSessions asks the user to pick any number of sessions
+func selectSessions(sessions []ssmSession) ([]ssmSession, error) {
+	var options []string
+	for _, s := range sessions {
+		target := s.Target
+		if s.TargetName != "" {
+			target = fmt.Sprintf("%s (%s)", s.TargetName, s.Target)
+		}
+		option := fmt.Sprintf("%s - %s - %s since %s", s.ID, target, sessionOwner(s.Owner), sessionTime(s.StartDate))
+		if location := locationLabel(s.Profile, s.Region); location != "" {
+			option += fmt.Sprintf(" [%s]", location)
+		}
+		options = append(options, option)
+	}
+
+	var selected []int
+	prompt := &survey.MultiSelect{
+		Message:  "Select sessions to terminate:",
+		Options:  options,
+		PageSize: 10,
+	}
+	if err := survey.AskOne(prompt, &selected); err != nil {
+		return nil, err
+	}
+
+	var picked []ssmSession
+	for _, i := range selected {
+		picked = append(picked, sessions[i])
+	}
+	return picked, nil
+}
+
+// terminateSessions terminates every session in its own account and region,
+// reporting each one. It fails if any session could not be terminated.
+func terminateSessions(ctx context.Context, cfg aws.Config, sessions []ssmSession) error {
+	failed := 0
+	for _, s := range sessions {
+		client := ssm.NewFromConfig(accountConfig(cfg, s.Profile, s.Region))
+		_, err := client.TerminateSession(ctx, &ssm.TerminateSessionInput{SessionId: aws.String(s.ID)})
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Failed to terminate %s: %v\n", s.ID, err)
+			failed++
+			continue
+		}
+		fmt.Printf("Terminated %s\n", s.ID)
+	}
+
+	if failed > 0 {
+		return fmt.Errorf("%d of %d sessions could not be terminated", failed, len(sessions))
+	}
+	return nil
+}
@@ -0,0 +1,126 @@
+package main
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseSessionState(t *testing.T) {
+	tests := []struct {
+		input   string
+		want    string
+		wantErr bool
+	}{
+		{"Active", sessionStateActive, false},
+		{"active", sessionStateActive, false},
+		{"HISTORY", sessionStateHistory, false},
+		{"ended", "", true},
+		{"", "", true},
+	}
+
+	for _, tt := range tests {
+		got, err := parseSessionState(tt.input)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("parseSessionState(%q): expected error %v, got %v", tt.input, tt.wantErr, err)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("parseSessionState(%q): expected %q, got %q", tt.input, tt.want, got)
+		}
+	}
+}
+
+func TestSessionOwner(t *testing.T) {
+	tests := []struct {
+		owner string
+		want  string
+	}{
+		{"arn:aws:sts::123456789012:assumed-role/Admin/alice", "assumed-role/Admin/alice"},
+		{"arn:aws:iam::123456789012:user/bob", "user/bob"},
+		{"bob", "bob"},
+		{"", ""},
+	}
+
+	for _, tt := range tests {
+		if got := sessionOwner(tt.owner); got != tt.want {
+			t.Errorf("sessionOwner(%q): expected %q, got %q", tt.owner, tt.want, got)
+		}
+	}
+}
+
+func TestNameSessionTargets(t *testing.T) {
+	sessions := []ssmSession{
+		{ID: "alice-1", Target: "i-web"},
+		{ID: "bob-2", Target: "i-unknown"},
+		{ID: "carol-3", Target: "mi-0123456789abcdef0"},
+	}
+	instances := []Instance{
+		{ID: "i-web", Name: "web-1"},
+		{ID: "i-db", Name: "db-1"},
+	}
+
+	nameSessionTargets(sessions, instances)
+
+	want := []string{"web-1", "", ""}
+	for i, s := range sessions {
+		if s.TargetName != want[i] {
+			t.Errorf("Expected session %s to be named %q, got %q", s.ID, want[i], s.TargetName)
+		}
+	}
+}
+
+func TestFindSessions(t *testing.T) {
+	sessions := []ssmSession{
+		{ID: "alice-1", Region: "us-east-1"},
+		{ID: "bob-2", Region: "eu-west-1"},
+	}
+
+	found, err := findSessions(sessions, []string{"bob-2", "alice-1"})
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if len(found) != 2 || found[0].Region != "eu-west-1" || found[1].Region != "us-east-1" {
+		t.Errorf("Expected the sessions in ID order with their regions, got %+v", found)
+	}
+
+	_, err = findSessions(sessions, []string{"alice-1", "dave-4", "erin-5"})
+	if err == nil || !strings.Contains(err.Error(), "dave-4, erin-5") {
+		t.Errorf("Expected an error naming the missing sessions, got %v", err)
+	}
+}
+
+func TestDisplaySessions(t *testing.T) {
+	started := time.Date(2024, 1, 2, 3, 4, 0, 0, time.Local)
+	sessions := []ssmSession{
+		{
+			ID:         "alice-0123456789abcdef0",
+			Target:     "i-0123456789abcdef0",
+			TargetName: "web-1",
+			Owner:      "arn:aws:sts::123456789012:assumed-role/Admin/alice",
+			Status:     "Connected",
+			Document:   "AWS-StartInteractiveCommand",
+			StartDate:  started,
+		},
+	}
+
+	var buf bytes.Buffer
+	displaySessions(&buf, sessions, false)
+	out := buf.String()
+
+	for _, want := range []string{"SESSION ID", "web-1", "assumed-role/Admin/alice", "Connected", "2024-01-02 03:04", "AWS-StartInteractiveCommand"} {
+		if !strings.Contains(out, want) {
+			t.Errorf("Expected output to contain %q, got:\n%s", want, out)
+		}
+	}
+	if strings.Contains(out, "ENDED") {
+		t.Errorf("Expected no ENDED column for active sessions, got:\n%s", out)
+	}
+
+	buf.Reset()
+	displaySessions(&buf, sessions, true)
+	if !strings.Contains(buf.String(), "ENDED") {
+		t.Errorf("Expected an ENDED column for ended sessions, got:\n%s", buf.String())
+	}
+}
@@ -0,0 +1,159 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+)
+
+// shellCacheTTL is how long the shells found on an instance are remembered
+const shellCacheTTL = 7 * 24 * time.Hour
+
+// shellProbe is what a probe found on one instance
+type shellProbe struct {
+	Checked   []string  `json:"checked"`
+	Available []string  `json:"available"`
+	ProbedAt  time.Time `json:"probedAt"`
+}
+
+// covers reports whether the probe checked every candidate and is recent
+// enough to trust
+func (p shellProbe) covers(candidates []string) bool {
+	if time.Since(p.ProbedAt) > shellCacheTTL {
+		return false
+	}
+	for _, c := range candidates {
+		if !slices.Contains(p.Checked, c) {
+			return false
+		}
+	}
+	return true
+}
+
+// pick returns the first candidate the probe found
+func (p shellProbe) pick(candidates []string) (string, bool) {
+	for _, c := range candidates {
+		if slices.Contains(p.Available, c) {
+			return c, true
+		}
+	}
+	return "", false
+}
+
+// shellCache maps instance IDs to what was found on them
+type shellCache map[string]shellProbe
+
+func shellCachePath() string {
+	return filepath.Join(cacheDir(), "shells.json")
+}
+
+// loadShellCache reads the shell cache. A missing or unreadable file gives
+// an empty cache, so instances are probed again.
+func loadShellCache() shellCache {
+	cache := shellCache{}
+
+	data, err := os.ReadFile(shellCachePath())
+	if err != nil {
+		if !errors.Is(err, os.ErrNotExist) {
+			fmt.Fprintf(os.Stderr, "Warning: could not read the shell cache: %v\n", err)
+		}
+		return cache
+	}
+	if err := json.Unmarshal(data, &cache); err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: ignoring corrupt shell cache %s: %v\n", shellCachePath(), err)
+		return shellCache{}
+	}
+	return cache
+}
+
+// save writes the shell cache, dropping expired probes
+func (c shellCache) save() error {
+	for id, probe := range c {
+		if time.Since(probe.ProbedAt) > shellCacheTTL {
+			delete(c, id)
+		}
+	}
+
+	data, err := json.MarshalIndent(c, "", "  ")
+	if err != nil {
+		return err
+	}
+	return writeFileAtomic(shellCachePath(), data)
+}
+
+// detectShell returns the first of candidates installed on the instance,
+// probing it with Run Command unless a recent probe is cached. fallback is
+// used when none is installed or the probe fails.
+func detectShell(ctx context.Context, cfg aws.Config, instance Instance, candidates []string, fallback string) string {
+	cache := loadShellCache()
+
+	probe, ok := cache[instance.ID]
+	if !ok || refreshCache || !probe.covers(candidates) {
+		fmt.Fprintf(os.Stderr, "Checking which shells %s has...\n", displayName(instance))
+
+		available, err := probeShells(ctx, cfg, instance, candidates)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Warning: could not detect the shells on %s, using %s: %v\n", instance.ID, fallback, err)
+			return fallback
+		}
+
+		probe = shellProbe{Checked: candidates, Available: available, ProbedAt: time.Now()}
+		cache[instance.ID] = probe
+		if err := cache.save(); err != nil {
+			fmt.Fprintf(os.Stderr, "Warning: could not write the shell cache: %v\n", err)
+		}
+	}
+
+	if shell, ok := probe.pick(candidates); ok {
+		return shell
+	}
+	fmt.Fprintf(os.Stderr, "Warning: none of %s found on %s, using %s\n", strings.Join(candidates, ", "), instance.ID, fallback)
+	return fallback
+}
+
+// probeShells lists which candidates are installed on the instance
+func probeShells(ctx context.Context, cfg aws.Config, instance Instance, candidates []string) ([]string, error) {
+	stdout, err := runProbe(ctx, cfg, instance, shellProbeScript(instance.Platform, candidates))
+	if err != nil {
+		return nil, err
+	}
+	return parseShellProbe(stdout, candidates), nil
+}
+
+// shellProbeScript returns a script that prints each installed candidate on
+// its own line
+func shellProbeScript(platform string, candidates []string) string {
+	quoted := make([]string, len(candidates))
+	if platform == "windows" {
+		for i, c := range candidates {
+			quoted[i] = "'" + strings.ReplaceAll(c, "'", "''") + "'"
+		}
+		return fmt.Sprintf("foreach ($s in @(%s)) { if (Get-Command $s -ErrorAction SilentlyContinue) { $s } }", strings.Join(quoted, ", "))
+	}
+
+	for i, c := range candidates {
+		quoted[i] = shellQuote(c)
+	}
+	return fmt.Sprintf(`for s in %s; do command -v "$s" >/dev/null 2>&1 && echo "$s"; done; exit 0`, strings.Join(quoted, " "))
+}
+
+// parseShellProbe returns the candidates listed in a probe's output,
+// ignoring anything else the instance printed
+func parseShellProbe(stdout string, candidates []string) []string {
+	available := []string{}
+	for _, line := range strings.Split(stdout, "\n") {
+		line = strings.TrimSpace(line)
+		if slices.Contains(candidates, line) && !slices.Contains(available, line) {
+			available = append(available, line)
+		}
+	}
+	return available
+}
@@ -0,0 +1,100 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestShellProbePick(t *testing.T) {
+	probe := shellProbe{
+		Checked:   []string{"zsh", "bash", "sh"},
+		Available: []string{"bash", "sh"},
+		ProbedAt:  time.Now(),
+	}
+
+	if got, ok := probe.pick([]string{"zsh", "bash", "sh"}); !ok || got != "bash" {
+		t.Errorf("Expected bash, got %q (%v)", got, ok)
+	}
+	if got, ok := probe.pick([]string{"sh", "bash"}); !ok || got != "sh" {
+		t.Errorf("Expected the preference order to win, got %q (%v)", got, ok)
+	}
+	if _, ok := probe.pick([]string{"zsh"}); ok {
+		t.Errorf("Expected no shell when none is installed")
+	}
+}
+
+func TestShellProbeCovers(t *testing.T) {
+	probe := shellProbe{Checked: []string{"zsh", "bash"}, ProbedAt: time.Now()}
+
+	if !probe.covers([]string{"bash", "zsh"}) {
+		t.Errorf("Expected a probe of the same candidates to cover them")
+	}
+	if probe.covers([]string{"zsh", "fish"}) {
+		t.Errorf("Expected a new candidate to need a probe")
+	}
+
+	probe.ProbedAt = time.Now().Add(-shellCacheTTL - time.Minute)
+	if probe.covers([]string{"zsh"}) {
+		t.Errorf("Expected an expired probe not to cover anything")
+	}
+}
+
+func TestShellProbeScript(t *testing.T) {
+	tests := []struct {
+		platform   string
+		candidates []string
+		want       string
+	}{
+		{
+			"linux",
+			[]string{"/bin/zsh", "bash", "my shell"},
+			`for s in /bin/zsh bash 'my shell'; do command -v "$s" >/dev/null 2>&1 && echo "$s"; done; exit 0`,
+		},
+		{
+			"windows",
+			[]string{"pwsh.exe", "powershell.exe"},
+			`foreach ($s in @('pwsh.exe', 'powershell.exe')) { if (Get-Command $s -ErrorAction SilentlyContinue) { $s } }`,
+		},
+	}
+
+	for _, tt := range tests {
+		if got := shellProbeScript(tt.platform, tt.candidates); got != tt.want {
+			t.Errorf("shellProbeScript(%q): expected %q, got %q", tt.platform, tt.want, got)
+		}
+	}
+}
+
+func TestParseShellProbe(t *testing.T) {
+	stdout := "Welcome to the bastion\r\n/bin/bash\r\nsh\n/bin/bash\n"
+
+	got := parseShellProbe(stdout, []string{"/bin/zsh", "/bin/bash", "sh"})
+	want := []string{"/bin/bash", "sh"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Expected %v, got %v", want, got)
+	}
+}
+
+func TestShellCacheRoundTrip(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+
+	if cache := loadShellCache(); len(cache) != 0 {
+		t.Fatalf("Expected an empty cache, got %v", cache)
+	}
+
+	cache := shellCache{
+		"i-fresh":   {Checked: []string{"zsh", "bash"}, Available: []string{"bash"}, ProbedAt: time.Now()},
+		"i-expired": {Checked: []string{"zsh"}, ProbedAt: time.Now().Add(-shellCacheTTL - time.Hour)},
+	}
+	if err := cache.save(); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	loaded := loadShellCache()
+	if _, ok := loaded["i-expired"]; ok {
+		t.Errorf("Expected expired probes to be dropped")
+	}
+	if got, ok := loaded["i-fresh"].pick([]string{"zsh", "bash"}); !ok || got != "bash" {
+		t.Errorf("Expected the cached probe to pick bash, got %q (%v)", got, ok)
+	}
+}
@@ -0,0 +1,178 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ec2/types"
+	"github.com/aws/aws-sdk-go-v2/service/ssm"
+)
+
+// instanceIDPattern matches EC2 instance IDs in both the short and long format
+var instanceIDPattern = regexp.MustCompile(`^i-([0-9a-f]{8}|[0-9a-f]{17})$`)
+
+// sshHostAliasPattern matches characters that can't be used in an ssh_config Host alias
+var sshHostAliasPattern = regexp.MustCompile(`[^A-Za-z0-9._-]+`)
+
+// sshConfigOptions controls the Host blocks written by writeSSHConfig
+type sshConfigOptions struct {
+	// ProxyCommand is the command ssh runs to reach the instance
+	ProxyCommand string
+	// User is written as the User option when set
+	User string
+	// Prefix is prepended to every Host alias
+	Prefix string
+}
+
+func handleEC2SSHProxy(ctx context.Context, cfg aws.Config, host, port string) {
+	// ssh owns stdin and stdout, so there is no picker and nothing but session
+	// data may be written to stdout
+	portNumber, err := strconv.Atoi(port)
+	if err != nil || validatePort(portNumber) != nil {
+		log.Fatalf("Invalid port %q", port)
+	}
+
+	// Narrow a live listing server-side; ssh runs this for every connection.
+	// The filter is built directly since a host may contain commas, which
+	// --filter expressions split on, and the narrowed listing isn't cached.
+	filterName := "tag:Name"
+	if instanceIDPattern.MatchString(host) {
+		filterName = "instance-id"
+	}
+	ec2ExtraFilters = append(ec2ExtraFilters, types.Filter{Name: aws.String(filterName), Values: []string{host}})
+	refreshCache, cacheTTL = true, 0
+
+	instances, err := listInstances(ctx, cfg)
+	if err != nil {
+		log.Fatalf("Failed to list instances: %v", err)
+	}
+
+	selectedInstance, err := resolveInstance(instances, host)
+	if err != nil {
+		log.Fatalf("Failed to resolve host: %v", err)
+	}
+
+	cfg = accountConfig(cfg, selectedInstance.Profile, selectedInstance.Region)
+
+	selectedInstance, err = refreshInstance(ctx, cfg, selectedInstance)
+	if err != nil {
+		log.Fatalf("Failed to describe instance: %v", err)
+	}
+
+	selectedInstance, err = ensureInstanceRunning(ctx, cfg, selectedInstance)
+	if err != nil {
+		log.Fatalf("Failed to start instance: %v", err)
+	}
+
+	if err := checkSessionTarget(selectedInstance); err != nil {
+		log.Fatalf("Failed to connect: %v", err)
+	}
+
+	startSessionInput := &ssm.StartSessionInput{
+		Target:       aws.String(selectedInstance.ID),
+		DocumentName: aws.String("AWS-StartSSHSession"),
+		Parameters: map[string][]string{
+			"portNumber": {strconv.Itoa(portNumber)},
+		},
+	}
+
+	if err := runSession(ctx, cfg, startSessionInput, "", nil); err != nil {
+		fatalSession("Failed to start SSH session: %v", err)
+	}
+}
+
+func handleEC2SSHConfig(ctx context.Context, cfg aws.Config, user, prefix string) {
+	// The config outlives this run, so it is written from a live listing
+	refreshCache = true
+
+	instances, err := listInstances(ctx, cfg)
+	if err != nil {
+		log.Fatalf("Failed to list instances: %v", err)
+	}
+
+	opts := sshConfigOptions{
+		ProxyCommand: sshProxyCommand(),
+		User:         user,
+		Prefix:       prefix,
+	}
+
+	writeSSHConfig(os.Stdout, instances, opts)
+}
+
+// sshProxyCommand builds the ProxyCommand that invokes this binary's
+// ssh-proxy. The profile and region are added per host by writeSSHConfig.
+func sshProxyCommand() string {
+	executable, err := os.Executable()
+	if err != nil {
+		executable = "aws-go-tools"
+	}
+
+	args := []string{quoteSSHArg(executable), "ec2", "ssh-proxy", "%h", "%p"}
+	return strings.Join(args, " ")
+}
+
+// writeSSHConfig writes an ssh_config Host block for every Linux instance.
+// Each block is reachable by instance ID and, when it is unique, by Name tag,
+// and pins ssh-proxy to the instance's profile and region.
+func writeSSHConfig(w io.Writer, instances []Instance, opts sshConfigOptions) {
+	nameCount := make(map[string]int)
+	for _, inst := range instances {
+		nameCount[sshHostAlias(inst.Name)]++
+	}
+
+	fmt.Fprintln(w, "# Generated by aws-go-tools ec2 ssh-config")
+
+	for _, inst := range instances {
+		// Windows instances don't run sshd by default
+		if inst.Platform == "windows" {
+			continue
+		}
+
+		aliases := []string{opts.Prefix + inst.ID}
+		if alias := sshHostAlias(inst.Name); alias != "" {
+			if nameCount[alias] == 1 {
+				aliases = append([]string{opts.Prefix + alias}, aliases...)
+			} else {
+				fmt.Fprintf(w, "\n# %q is shared by several instances; use the instance ID\n", inst.Name)
+			}
+		}
+
+		fmt.Fprintf(w, "\nHost %s\n", strings.Join(aliases, " "))
+		fmt.Fprintf(w, "    HostName %s\n", inst.ID)
+		if opts.User != "" {
+			fmt.Fprintf(w, "    User %s\n", opts.User)
+		}
+		fmt.Fprintf(w, "    ProxyCommand %s\n", instanceProxyCommand(opts.ProxyCommand, inst))
+	}
+}
+
+// instanceProxyCommand pins a ProxyCommand to the instance's profile and region
+func instanceProxyCommand(proxyCommand string, inst Instance) string {
+	if inst.Profile != "" {
+		proxyCommand += " --profile " + quoteSSHArg(inst.Profile)
+	}
+	if inst.Region != "" {
+		proxyCommand += " --region " + quoteSSHArg(inst.Region)
+	}
+	return proxyCommand
+}
+
+// sshHostAlias turns an instance name into a usable Host alias
+func sshHostAlias(name string) string {
+	return strings.Trim(sshHostAliasPattern.ReplaceAllString(name, "-"), "-")
+}
+
+// quoteSSHArg quotes an argument for use in a ProxyCommand if it needs it
+func quoteSSHArg(arg string) string {
+	if arg != "" && !strings.ContainsAny(arg, " \t\"'\\") {
+		return arg
+	}
+	return `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(arg) + `"`
+}
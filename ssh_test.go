@@ -0,0 +1,92 @@
+package main
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestInstanceIDPattern(t *testing.T) {
+	tests := []struct {
+		id   string
+		want bool
+	}{
+		{"i-0123456789abcdef0", true},
+		{"i-12345678", true},
+		{"web-server", false},
+		{"i-web", false},
+		{"i-0123456789ABCDEF0", false},
+	}
+
+	for _, tt := range tests {
+		if got := instanceIDPattern.MatchString(tt.id); got != tt.want {
+			t.Errorf("%s: expected %v, got %v", tt.id, tt.want, got)
+		}
+	}
+}
+
+func TestSSHHostAlias(t *testing.T) {
+	tests := []struct {
+		name string
+		want string
+	}{
+		{"web-1", "web-1"},
+		{"Web Server (prod)", "Web-Server-prod"},
+		{"api.internal", "api.internal"},
+		{"", ""},
+	}
+
+	for _, tt := range tests {
+		if got := sshHostAlias(tt.name); got != tt.want {
+			t.Errorf("%q: expected %q, got %q", tt.name, tt.want, got)
+		}
+	}
+}
+
+func TestQuoteSSHArg(t *testing.T) {
+	if got := quoteSSHArg("/usr/local/bin/aws-go-tools"); got != "/usr/local/bin/aws-go-tools" {
+		t.Errorf("Expected plain path to stay unquoted, got %s", got)
+	}
+	if got := quoteSSHArg("/Users/me/My Tools/aws-go-tools"); got != `"/Users/me/My Tools/aws-go-tools"` {
+		t.Errorf("Expected path with spaces to be quoted, got %s", got)
+	}
+}
+
+func TestWriteSSHConfig(t *testing.T) {
+	instances := []Instance{
+		{ID: "i-0123456789abcdef0", Name: "web-1", Platform: "linux"},
+		{ID: "i-0123456789abcdef1", Name: "worker", Platform: "linux"},
+		{ID: "i-0123456789abcdef2", Name: "worker", Platform: "linux"},
+		{ID: "i-0123456789abcdef3", Name: "ad-controller", Platform: "windows"},
+		{ID: "i-0123456789abcdef4", Platform: "linux"},
+		{ID: "i-0123456789abcdef5", Name: "eu-web", Platform: "linux", Profile: "prod", Region: "eu-west-1"},
+	}
+
+	var buf bytes.Buffer
+	writeSSHConfig(&buf, instances, sshConfigOptions{
+		ProxyCommand: "aws-go-tools ec2 ssh-proxy %h %p",
+		User:         "ec2-user",
+		Prefix:       "dev-",
+	})
+	config := buf.String()
+
+	expected := []string{
+		"Host dev-web-1 dev-i-0123456789abcdef0\n    HostName i-0123456789abcdef0\n    User ec2-user\n    ProxyCommand aws-go-tools ec2 ssh-proxy %h %p\n",
+		"Host dev-i-0123456789abcdef1\n",
+		"Host dev-i-0123456789abcdef2\n",
+		"Host dev-i-0123456789abcdef4\n",
+		"Host dev-eu-web dev-i-0123456789abcdef5\n    HostName i-0123456789abcdef5\n    User ec2-user\n    ProxyCommand aws-go-tools ec2 ssh-proxy %h %p --profile prod --region eu-west-1\n",
+	}
+	for _, want := range expected {
+		if !strings.Contains(config, want) {
+			t.Errorf("Expected config to contain %q, got:\n%s", want, config)
+		}
+	}
+
+	if strings.Contains(config, "Host dev-worker") {
+		t.Error("Duplicate names should not become Host aliases")
+	}
+	if strings.Contains(config, "ad-controller") {
+		t.Error("Windows instances should be skipped")
+	}
+}
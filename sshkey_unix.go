@@ -0,0 +1,56 @@
+//go:build !windows
+
+package main
+
+import (
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/agent"
+)
+
+// loadSSHKey serves key from an in-memory ssh-agent on a socket in dir, so
+// the private key is never written to disk. ssh selects it with the public
+// key file. stop closes the agent.
+func loadSSHKey(dir string, key ephemeralKey) (identity sshIdentity, stop func(), err error) {
+	private, err := ssh.ParseRawPrivateKey(key.PrivateKey)
+	if err != nil {
+		return sshIdentity{}, nil, fmt.Errorf("failed to parse key: %w", err)
+	}
+
+	keyring := agent.NewKeyring()
+	if err := keyring.Add(agent.AddedKey{PrivateKey: private, Comment: ephemeralKeyComment}); err != nil {
+		return sshIdentity{}, nil, fmt.Errorf("failed to load key: %w", err)
+	}
+
+	identity = sshIdentity{
+		KeyFile: filepath.Join(dir, "id_ed25519.pub"),
+		Agent:   filepath.Join(dir, "agent.sock"),
+	}
+	if err := os.WriteFile(identity.KeyFile, []byte(key.PublicKey+"\n"), 0o600); err != nil {
+		return sshIdentity{}, nil, fmt.Errorf("failed to write public key: %w", err)
+	}
+
+	listener, err := net.Listen("unix", identity.Agent)
+	if err != nil {
+		return sshIdentity{}, nil, fmt.Errorf("failed to start ssh agent: %w", err)
+	}
+
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				defer conn.Close()
+				_ = agent.ServeAgent(keyring, conn)
+			}()
+		}
+	}()
+
+	return identity, func() { listener.Close() }, nil
+}
@@ -0,0 +1,20 @@
+//go:build windows
+
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// loadSSHKey writes key to dir for ssh to read with -i, as Windows OpenSSH
+// can't use an agent on a unix socket. dir is private to the user and is
+// removed by the caller.
+func loadSSHKey(dir string, key ephemeralKey) (identity sshIdentity, stop func(), err error) {
+	identity = sshIdentity{KeyFile: filepath.Join(dir, "id_ed25519")}
+	if err := os.WriteFile(identity.KeyFile, key.PrivateKey, 0o600); err != nil {
+		return sshIdentity{}, nil, fmt.Errorf("failed to write key: %w", err)
+	}
+	return identity, func() {}, nil
+}
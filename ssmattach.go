@@ -0,0 +1,205 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ssm"
+	"golang.org/x/term"
+)
+
+// terminalPollInterval is how often the terminal size is checked for changes
+const terminalPollInterval = 500 * time.Millisecond
+
+// runNativeSession attaches a started session with the built-in client: shell
+// sessions to the terminal, port sessions to a local port when the input asks
+// for one and to stdin/stdout otherwise (e.g. ssh-proxy).
+func runNativeSession(ctx context.Context, session *ssm.StartSessionOutput, input *ssm.StartSessionInput, readyMessage string, rec *sessionRecorder) error {
+	ch, err := openDataChannel(ctx, aws.ToString(session.StreamUrl), aws.ToString(session.TokenValue))
+	if err != nil {
+		return err
+	}
+	defer ch.Close()
+
+	// Ending the session context, e.g. on Ctrl-C in a port session, closes
+	// the channel and so ends the session
+	stop := context.AfterFunc(ctx, func() { ch.shutdown(context.Cause(ctx)) })
+	defer stop()
+
+	sessionType, err := ch.waitReady(ctx)
+	if err != nil {
+		return err
+	}
+
+	if readyMessage != "" {
+		fmt.Fprintln(os.Stderr, readyMessage)
+	}
+
+	localPort := 0
+	if port := input.Parameters["localPortNumber"]; len(port) > 0 {
+		if localPort, err = strconv.Atoi(port[0]); err != nil {
+			return fmt.Errorf("invalid local port %q: %w", port[0], err)
+		}
+	}
+
+	switch {
+	case sessionType == sessionTypePort && localPort != 0:
+		err = serveLocalPort(ch, localPort)
+	case sessionType == sessionTypePort:
+		err = attachStdio(ch, os.Stdin, os.Stdout)
+	default:
+		err = attachTerminal(ch, rec)
+	}
+	if err != nil {
+		return err
+	}
+
+	if output := ch.closedOutput(); output != "" && readyMessage != "" {
+		fmt.Fprintf(os.Stderr, "\n%s\n", output)
+	}
+	if code := ch.exitStatus(); code != 0 {
+		return &sessionExitError{code: code}
+	}
+	return nil
+}
+
+// attachTerminal connects a shell session to the terminal in raw mode, so
+// keys such as Ctrl-C reach the remote shell, keeping the remote terminal
+// the same size as the local one. The session is recorded to rec unless it
+// is nil.
+func attachTerminal(ch *dataChannel, rec *sessionRecorder) error {
+	if fd := int(os.Stdin.Fd()); term.IsTerminal(fd) {
+		state, err := term.MakeRaw(fd)
+		if err != nil {
+			return fmt.Errorf("failed to set the terminal to raw mode: %w", err)
+		}
+		defer term.Restore(fd, state)
+	}
+
+	var in io.Reader = os.Stdin
+	var out io.Writer = os.Stdout
+	if rec != nil {
+		in = io.TeeReader(os.Stdin, rec.input())
+		out = io.MultiWriter(os.Stdout, rec.output())
+	}
+
+	stop := make(chan struct{})
+	defer close(stop)
+	go watchTerminalSize(ch, rec, stop)
+
+	go io.Copy(ch, in)
+
+	_, err := io.Copy(out, ch)
+	return err
+}
+
+// watchTerminalSize sends the terminal size to the session, and again
+// whenever it changes, until stop is closed
+func watchTerminalSize(ch *dataChannel, rec *sessionRecorder, stop <-chan struct{}) {
+	ticker := time.NewTicker(terminalPollInterval)
+	defer ticker.Stop()
+
+	cols, rows := 0, 0
+	for {
+		if c, r := terminalSize(); c != cols || r != rows {
+			if cols != 0 && rec != nil {
+				rec.resize(c, r)
+			}
+			cols, rows = c, r
+			if err := ch.resize(cols, rows); err != nil {
+				return
+			}
+		}
+
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// attachStdio relays a port session to in and out. The session is
+// terminated once in is exhausted.
+func attachStdio(ch *dataChannel, in io.Reader, out io.Writer) error {
+	go func() {
+		if _, err := io.Copy(ch, in); err == nil {
+			ch.sendFlag(portFlagTerminateSession)
+		}
+	}()
+
+	_, err := io.Copy(out, ch)
+	return err
+}
+
+// serveLocalPort relays connections to 127.0.0.1:port through a port
+// session until the session ends. The session carries one stream, so
+// connections are served one at a time; the agent is told when each one
+// closes so it reconnects to the remote port for the next.
+func serveLocalPort(ch *dataChannel, port int) error {
+	listener, err := net.Listen("tcp", net.JoinHostPort("127.0.0.1", strconv.Itoa(port)))
+	if err != nil {
+		return fmt.Errorf("failed to listen on local port %d: %w", port, err)
+	}
+	defer listener.Close()
+
+	var mu sync.Mutex
+	var current net.Conn
+
+	// Session output goes to the current connection, if any
+	pumpErr := make(chan error, 1)
+	go func() {
+		buf := make([]byte, 32*1024)
+		for {
+			n, err := ch.Read(buf)
+			mu.Lock()
+			if n > 0 && current != nil {
+				current.Write(buf[:n])
+			}
+			if err != nil && current != nil {
+				current.Close()
+			}
+			mu.Unlock()
+
+			if err != nil {
+				pumpErr <- err
+				listener.Close()
+				return
+			}
+		}
+	}()
+
+	for {
+		conn, err := listener.Accept()
+		if err != nil {
+			break
+		}
+
+		mu.Lock()
+		current = conn
+		mu.Unlock()
+
+		io.Copy(ch, conn)
+
+		mu.Lock()
+		current = nil
+		mu.Unlock()
+		conn.Close()
+
+		if err := ch.sendFlag(portFlagDisconnectToPort); err != nil {
+			break
+		}
+	}
+
+	if err := <-pumpErr; err != io.EOF {
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,240 @@
+package main
+
+import (
+	"bytes"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Session Manager data channel messages, as exchanged with the SSM agent over
+// the session's websocket. Every binary frame is a fixed header followed by
+// the payload:
+//
+//	offset  size  field
+//	     0     4  header length (116, excluding the payload length)
+//	     4    32  message type, padded with spaces
+//	    36     4  schema version
+//	    40     8  created date, milliseconds since the epoch
+//	    48     8  sequence number
+//	    56     8  flags
+//	    64    16  message ID (UUID, least significant half first)
+//	    80    32  SHA-256 digest of the payload
+//	   112     4  payload type
+//	   116     4  payload length
+//	   120     -  payload
+const (
+	ssmHeaderLength      = 116
+	ssmMessageTypeLength = 32
+	ssmPayloadOffset     = ssmHeaderLength + 4
+)
+
+// Message types
+const (
+	msgInputStreamData  = "input_stream_data"
+	msgOutputStreamData = "output_stream_data"
+	msgAcknowledge      = "acknowledge"
+	msgChannelClosed    = "channel_closed"
+	msgStartPublication = "start_publication"
+	msgPausePublication = "pause_publication"
+)
+
+// Payload types of stream data messages
+const (
+	payloadOutput               uint32 = 1
+	payloadError                uint32 = 2
+	payloadSize                 uint32 = 3
+	payloadParameter            uint32 = 4
+	payloadHandshakeRequest     uint32 = 5
+	payloadHandshakeResponse    uint32 = 6
+	payloadHandshakeComplete    uint32 = 7
+	payloadEncChallengeRequest  uint32 = 8
+	payloadEncChallengeResponse uint32 = 9
+	payloadFlag                 uint32 = 10
+	payloadStdErr               uint32 = 11
+	payloadExitCode             uint32 = 12
+)
+
+// Message flags
+const (
+	flagData uint64 = 0
+	flagSyn  uint64 = 1
+	flagFin  uint64 = 2
+	flagAck  uint64 = 3
+)
+
+// Values of payloadFlag messages in port sessions
+const (
+	portFlagDisconnectToPort   uint32 = 1
+	portFlagTerminateSession   uint32 = 2
+	portFlagConnectToPortError uint32 = 3
+)
+
+// ssmMessage is one data channel message
+type ssmMessage struct {
+	MessageType    string
+	SchemaVersion  uint32
+	CreatedDate    time.Time
+	SequenceNumber int64
+	Flags          uint64
+	MessageID      messageID
+	PayloadType    uint32
+	Payload        []byte
+}
+
+// messageID is a UUID
+type messageID [16]byte
+
+func newMessageID() messageID {
+	var id messageID
+	if _, err := rand.Read(id[:]); err != nil {
+		panic(fmt.Sprintf("reading random bytes: %v", err))
+	}
+	id[6] = id[6]&0x0f | 0x40 // version 4
+	id[8] = id[8]&0x3f | 0x80 // RFC 4122 variant
+	return id
+}
+
+func (id messageID) String() string {
+	h := hex.EncodeToString(id[:])
+	return h[0:8] + "-" + h[8:12] + "-" + h[12:16] + "-" + h[16:20] + "-" + h[20:32]
+}
+
+// marshal encodes the message as a binary frame
+func (m ssmMessage) marshal() []byte {
+	buf := make([]byte, ssmPayloadOffset+len(m.Payload))
+
+	binary.BigEndian.PutUint32(buf[0:4], ssmHeaderLength)
+	copy(buf[4:36], bytes.Repeat([]byte{' '}, ssmMessageTypeLength))
+	copy(buf[4:36], m.MessageType)
+	binary.BigEndian.PutUint32(buf[36:40], m.SchemaVersion)
+	binary.BigEndian.PutUint64(buf[40:48], uint64(m.CreatedDate.UnixMilli()))
+	binary.BigEndian.PutUint64(buf[48:56], uint64(m.SequenceNumber))
+	binary.BigEndian.PutUint64(buf[56:64], m.Flags)
+	copy(buf[64:72], m.MessageID[8:])
+	copy(buf[72:80], m.MessageID[:8])
+	digest := sha256.Sum256(m.Payload)
+	copy(buf[80:112], digest[:])
+	binary.BigEndian.PutUint32(buf[112:116], m.PayloadType)
+	binary.BigEndian.PutUint32(buf[116:120], uint32(len(m.Payload)))
+	copy(buf[ssmPayloadOffset:], m.Payload)
+
+	return buf
+}
+
+// unmarshalSSMMessage decodes a binary frame, checking its lengths and digest
+func unmarshalSSMMessage(data []byte) (ssmMessage, error) {
+	var m ssmMessage
+
+	if len(data) < ssmPayloadOffset {
+		return m, fmt.Errorf("message of %d bytes is shorter than its header", len(data))
+	}
+	headerLength := binary.BigEndian.Uint32(data[0:4])
+	if headerLength < ssmHeaderLength || int(headerLength)+4 > len(data) {
+		return m, fmt.Errorf("invalid header length %d", headerLength)
+	}
+
+	m.MessageType = strings.TrimRight(string(bytes.TrimRight(data[4:36], "\x00")), " ")
+	m.SchemaVersion = binary.BigEndian.Uint32(data[36:40])
+	m.CreatedDate = time.UnixMilli(int64(binary.BigEndian.Uint64(data[40:48])))
+	m.SequenceNumber = int64(binary.BigEndian.Uint64(data[48:56]))
+	m.Flags = binary.BigEndian.Uint64(data[56:64])
+	copy(m.MessageID[8:], data[64:72])
+	copy(m.MessageID[:8], data[72:80])
+	m.PayloadType = binary.BigEndian.Uint32(data[112:116])
+
+	payloadLength := binary.BigEndian.Uint32(data[headerLength : headerLength+4])
+	payloadStart := int(headerLength) + 4
+	if uint64(payloadStart)+uint64(payloadLength) > uint64(len(data)) {
+		return m, fmt.Errorf("payload length %d exceeds the message", payloadLength)
+	}
+	m.Payload = data[payloadStart : payloadStart+int(payloadLength)]
+
+	if digest := sha256.Sum256(m.Payload); !bytes.Equal(digest[:], data[80:112]) {
+		return m, fmt.Errorf("payload digest mismatch in %s message %d", m.MessageType, m.SequenceNumber)
+	}
+
+	return m, nil
+}
+
+// openDataChannelInput is the first, text frame sent on the websocket
+type openDataChannelInput struct {
+	MessageSchemaVersion string `json:"MessageSchemaVersion"`
+	RequestID            string `json:"RequestId"`
+	TokenValue           string `json:"TokenValue"`
+	ClientID             string `json:"ClientId"`
+	ClientVersion        string `json:"ClientVersion"`
+}
+
+// acknowledgeContent is the payload of an acknowledge message
+type acknowledgeContent struct {
+	AcknowledgedMessageType           string `json:"AcknowledgedMessageType"`
+	AcknowledgedMessageID             string `json:"AcknowledgedMessageId"`
+	AcknowledgedMessageSequenceNumber int64  `json:"AcknowledgedMessageSequenceNumber"`
+	IsSequentialMessage               bool   `json:"IsSequentialMessage"`
+}
+
+// channelClosedContent is the payload of a channel_closed message
+type channelClosedContent struct {
+	SessionID string `json:"SessionId"`
+	Output    string `json:"Output"`
+}
+
+// Handshake action types and statuses
+const (
+	actionSessionType   = "SessionType"
+	actionKMSEncryption = "KMSEncryption"
+
+	actionStatusSuccess     = 1
+	actionStatusFailed      = 2
+	actionStatusUnsupported = 3
+)
+
+// Session types announced in the handshake
+const (
+	sessionTypeShell       = "Standard_Stream"
+	sessionTypeInteractive = "InteractiveCommands"
+	sessionTypePort        = "Port"
+)
+
+type handshakeRequest struct {
+	AgentVersion           string                  `json:"AgentVersion"`
+	RequestedClientActions []requestedClientAction `json:"RequestedClientActions"`
+}
+
+type requestedClientAction struct {
+	ActionType       string          `json:"ActionType"`
+	ActionParameters json.RawMessage `json:"ActionParameters"`
+}
+
+type sessionTypeParameters struct {
+	SessionType string `json:"SessionType"`
+}
+
+type handshakeResponse struct {
+	ClientVersion          string                  `json:"ClientVersion"`
+	ProcessedClientActions []processedClientAction `json:"ProcessedClientActions"`
+	Errors                 []string                `json:"Errors"`
+}
+
+type processedClientAction struct {
+	ActionType   string `json:"ActionType"`
+	ActionStatus int    `json:"ActionStatus"`
+	Error        string `json:"Error,omitempty"`
+}
+
+type handshakeComplete struct {
+	HandshakeTimeToComplete time.Duration `json:"HandshakeTimeToComplete"`
+	CustomerMessage         string        `json:"CustomerMessage"`
+}
+
+// terminalSizeContent is the payload of a payloadSize message
+type terminalSizeContent struct {
+	Cols int `json:"cols"`
+	Rows int `json:"rows"`
+}
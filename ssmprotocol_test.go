@@ -0,0 +1,87 @@
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"regexp"
+	"testing"
+	"time"
+)
+
+func TestSSMMessageRoundTrip(t *testing.T) {
+	msg := ssmMessage{
+		MessageType:    msgInputStreamData,
+		SchemaVersion:  1,
+		CreatedDate:    time.UnixMilli(1704164645123),
+		SequenceNumber: 42,
+		Flags:          flagSyn,
+		MessageID:      newMessageID(),
+		PayloadType:    payloadOutput,
+		Payload:        []byte("ls -la\r"),
+	}
+
+	data := msg.marshal()
+	if len(data) != ssmPayloadOffset+len(msg.Payload) {
+		t.Fatalf("Expected %d bytes, got %d", ssmPayloadOffset+len(msg.Payload), len(data))
+	}
+	if got := binary.BigEndian.Uint32(data[0:4]); got != ssmHeaderLength {
+		t.Errorf("Expected header length %d, got %d", ssmHeaderLength, got)
+	}
+	if got := string(data[4:36]); got != "input_stream_data               " {
+		t.Errorf("Expected the message type padded with spaces, got %q", got)
+	}
+	if !bytes.Equal(data[64:72], msg.MessageID[8:]) || !bytes.Equal(data[72:80], msg.MessageID[:8]) {
+		t.Errorf("Expected the least significant half of the message ID first")
+	}
+
+	decoded, err := unmarshalSSMMessage(data)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if decoded.MessageType != msg.MessageType || decoded.SchemaVersion != 1 || decoded.SequenceNumber != 42 ||
+		decoded.Flags != flagSyn || decoded.MessageID != msg.MessageID || decoded.PayloadType != payloadOutput ||
+		!decoded.CreatedDate.Equal(msg.CreatedDate) || string(decoded.Payload) != "ls -la\r" {
+		t.Errorf("Expected %+v, got %+v", msg, decoded)
+	}
+}
+
+func TestUnmarshalSSMMessageErrors(t *testing.T) {
+	valid := ssmMessage{MessageType: msgOutputStreamData, Payload: []byte("hello")}.marshal()
+
+	corrupt := append([]byte(nil), valid...)
+	corrupt[len(corrupt)-1] = 'X'
+
+	truncated := valid[:len(valid)-2]
+
+	badHeader := append([]byte(nil), valid...)
+	binary.BigEndian.PutUint32(badHeader[0:4], 4)
+
+	tests := []struct {
+		name string
+		data []byte
+	}{
+		{"empty", nil},
+		{"shorter than the header", valid[:50]},
+		{"digest mismatch", corrupt},
+		{"truncated payload", truncated},
+		{"bad header length", badHeader},
+	}
+
+	for _, tt := range tests {
+		if _, err := unmarshalSSMMessage(tt.data); err == nil {
+			t.Errorf("Expected an error for %s", tt.name)
+		}
+	}
+}
+
+func TestMessageID(t *testing.T) {
+	uuidPattern := regexp.MustCompile(`^[0-9a-f]{8}-[0-9a-f]{4}-4[0-9a-f]{3}-[89ab][0-9a-f]{3}-[0-9a-f]{12}$`)
+
+	a, b := newMessageID(), newMessageID()
+	if a == b {
+		t.Errorf("Expected different message IDs, got %s twice", a)
+	}
+	if !uuidPattern.MatchString(a.String()) {
+		t.Errorf("Expected a version 4 UUID, got %s", a)
+	}
+}
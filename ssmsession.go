@@ -0,0 +1,527 @@
+package main
+
+import (
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// ssmClientVersion is reported to the agent. From 1.1.70 the agent
+// multiplexes port sessions over smux, which this client doesn't speak, so an
+// earlier version keeps them to one plain stream.
+const ssmClientVersion = "1.1.61.0"
+
+// streamChunkSize is the largest payload sent in one input message
+const streamChunkSize = 1024
+
+// Retransmission and keep-alive timing, variables so tests can shorten them
+var (
+	resendInterval    = 500 * time.Millisecond
+	resendTimeout     = 2 * time.Minute
+	keepAliveInterval = 5 * time.Minute
+)
+
+// errKMSEncryption is returned for sessions that require KMS encryption
+var errKMSEncryption = errors.New("the session requires KMS encryption, which the built-in client doesn't support")
+
+// dataChannel is the client side of a Session Manager session: the websocket
+// to the session's StreamUrl, speaking the agent's message protocol. Output is
+// delivered in sequence order through Read; Write sends input, which is
+// retransmitted until the agent acknowledges it.
+type dataChannel struct {
+	conn    *websocket.Conn
+	writeMu sync.Mutex // one writer at a time; held while sequence numbers are allocated
+
+	mu       sync.Mutex
+	cond     *sync.Cond // signalled when publication resumes or the channel closes
+	nextSeq  int64
+	unacked  map[int64]*unackedMessage
+	paused   bool
+	closed   bool
+	err      error
+	output   string
+	exitCode int
+
+	// Only used by the read loop
+	expectedSeq int64
+	outOfOrder  map[int64]ssmMessage
+
+	reader *io.PipeReader
+	writer *io.PipeWriter
+
+	sessionType string
+	ready       chan struct{}
+	readyOnce   sync.Once
+	done        chan struct{}
+}
+
+type unackedMessage struct {
+	seq       int64
+	data      []byte
+	firstSent time.Time
+	lastSent  time.Time
+}
+
+// openDataChannel connects to a session's stream and opens its data channel
+// with the session token
+func openDataChannel(ctx context.Context, streamURL, token string) (*dataChannel, error) {
+	conn, _, err := websocket.DefaultDialer.DialContext(ctx, streamURL, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to the session stream: %w", err)
+	}
+
+	open := openDataChannelInput{
+		MessageSchemaVersion: "1.0",
+		RequestID:            newMessageID().String(),
+		TokenValue:           token,
+		ClientID:             newMessageID().String(),
+		ClientVersion:        ssmClientVersion,
+	}
+	if err := conn.WriteJSON(open); err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("failed to open the data channel: %w", err)
+	}
+
+	reader, writer := io.Pipe()
+	c := &dataChannel{
+		conn:       conn,
+		unacked:    map[int64]*unackedMessage{},
+		outOfOrder: map[int64]ssmMessage{},
+		reader:     reader,
+		writer:     writer,
+		ready:      make(chan struct{}),
+		done:       make(chan struct{}),
+	}
+	c.cond = sync.NewCond(&c.mu)
+
+	go c.readLoop()
+	go c.resendLoop(resendInterval, resendTimeout, keepAliveInterval)
+	return c, nil
+}
+
+// waitReady waits for the agent's handshake and returns the session type. An
+// agent that doesn't hand shake is ready with its first output, and the
+// session type is empty.
+func (c *dataChannel) waitReady(ctx context.Context) (string, error) {
+	select {
+	case <-c.ready:
+		return c.sessionType, nil
+	case <-c.done:
+		if err := c.closeErr(); err != io.EOF {
+			return "", err
+		}
+		return "", fmt.Errorf("the session closed before it started: %s", c.closedOutput())
+	case <-ctx.Done():
+		return "", ctx.Err()
+	}
+}
+
+func (c *dataChannel) markReady() {
+	c.readyOnce.Do(func() { close(c.ready) })
+}
+
+// Read reads session output, returning io.EOF once the agent closes the channel
+func (c *dataChannel) Read(p []byte) (int, error) {
+	return c.reader.Read(p)
+}
+
+// Write sends p as session input, waiting while the agent has paused
+// publication
+func (c *dataChannel) Write(p []byte) (int, error) {
+	written := 0
+	for len(p) > 0 {
+		if err := c.waitPublishing(); err != nil {
+			return written, err
+		}
+
+		n := min(len(p), streamChunkSize)
+		if err := c.send(payloadOutput, append([]byte(nil), p[:n]...)); err != nil {
+			return written, err
+		}
+		written += n
+		p = p[n:]
+	}
+	return written, nil
+}
+
+// resize tells the agent the size of the local terminal
+func (c *dataChannel) resize(cols, rows int) error {
+	payload, err := json.Marshal(terminalSizeContent{Cols: cols, Rows: rows})
+	if err != nil {
+		return err
+	}
+	return c.send(payloadSize, payload)
+}
+
+// sendFlag sends a port session flag such as portFlagTerminateSession
+func (c *dataChannel) sendFlag(flag uint32) error {
+	payload := make([]byte, 4)
+	binary.BigEndian.PutUint32(payload, flag)
+	return c.send(payloadFlag, payload)
+}
+
+// Close closes the websocket. Session output that was not read is lost.
+func (c *dataChannel) Close() error {
+	c.shutdown(nil)
+	return nil
+}
+
+// closedOutput is the message the agent sent when it closed the channel
+func (c *dataChannel) closedOutput() string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.output
+}
+
+// exitStatus is the exit code the agent reported for the session's command or
+// shell, zero if it reported none
+func (c *dataChannel) exitStatus() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.exitCode
+}
+
+// closeErr is why the channel closed: io.EOF when it closed normally
+func (c *dataChannel) closeErr() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.err != nil {
+		return c.err
+	}
+	return io.EOF
+}
+
+// send sends a stream data message with the next sequence number and keeps
+// it for retransmission until it is acknowledged
+func (c *dataChannel) send(payloadType uint32, payload []byte) error {
+	c.writeMu.Lock()
+	defer c.writeMu.Unlock()
+
+	c.mu.Lock()
+	if c.closed {
+		c.mu.Unlock()
+		return c.closeErr()
+	}
+	seq := c.nextSeq
+	c.nextSeq++
+
+	flags := flagData
+	if seq == 0 {
+		flags = flagSyn
+	}
+	now := time.Now()
+	msg := ssmMessage{
+		MessageType:    msgInputStreamData,
+		SchemaVersion:  1,
+		CreatedDate:    now,
+		SequenceNumber: seq,
+		Flags:          flags,
+		MessageID:      newMessageID(),
+		PayloadType:    payloadType,
+		Payload:        payload,
+	}
+	data := msg.marshal()
+	c.unacked[seq] = &unackedMessage{seq: seq, data: data, firstSent: now, lastSent: now}
+	c.mu.Unlock()
+
+	return c.conn.WriteMessage(websocket.BinaryMessage, data)
+}
+
+// write sends a frame that takes no sequence number, or a retransmission
+func (c *dataChannel) write(data []byte) error {
+	c.writeMu.Lock()
+	defer c.writeMu.Unlock()
+	return c.conn.WriteMessage(websocket.BinaryMessage, data)
+}
+
+func (c *dataChannel) waitPublishing() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for c.paused && !c.closed {
+		c.cond.Wait()
+	}
+	if c.closed {
+		if c.err != nil {
+			return c.err
+		}
+		return io.ErrClosedPipe
+	}
+	return nil
+}
+
+// shutdown closes the channel once, recording err as the reason
+func (c *dataChannel) shutdown(err error) {
+	c.mu.Lock()
+	if c.closed {
+		c.mu.Unlock()
+		return
+	}
+	c.closed = true
+	c.err = err
+	c.cond.Broadcast()
+	c.mu.Unlock()
+
+	if err != nil {
+		c.writer.CloseWithError(err)
+	} else {
+		c.writer.Close()
+	}
+	close(c.done)
+	c.conn.Close()
+}
+
+func (c *dataChannel) readLoop() {
+	for {
+		kind, data, err := c.conn.ReadMessage()
+		if err != nil {
+			c.shutdown(fmt.Errorf("session stream closed: %w", err))
+			return
+		}
+		if kind != websocket.BinaryMessage {
+			continue
+		}
+
+		// A corrupt message is not acknowledged, so the agent sends it again
+		msg, err := unmarshalSSMMessage(data)
+		if err != nil {
+			continue
+		}
+
+		closed, err := c.handle(msg)
+		if err != nil || closed {
+			c.shutdown(err)
+			return
+		}
+	}
+}
+
+// handle processes one message from the agent and reports whether it closed
+// the channel
+func (c *dataChannel) handle(msg ssmMessage) (bool, error) {
+	switch msg.MessageType {
+	case msgOutputStreamData:
+		return false, c.receive(msg)
+
+	case msgAcknowledge:
+		var ack acknowledgeContent
+		if err := json.Unmarshal(msg.Payload, &ack); err == nil {
+			c.mu.Lock()
+			delete(c.unacked, ack.AcknowledgedMessageSequenceNumber)
+			c.mu.Unlock()
+		}
+
+	case msgChannelClosed:
+		var closed channelClosedContent
+		_ = json.Unmarshal(msg.Payload, &closed)
+		c.mu.Lock()
+		c.output = closed.Output
+		c.mu.Unlock()
+		return true, nil
+
+	case msgPausePublication, msgStartPublication:
+		c.mu.Lock()
+		c.paused = msg.MessageType == msgPausePublication
+		c.cond.Broadcast()
+		c.mu.Unlock()
+	}
+	return false, nil
+}
+
+// receive acknowledges a stream data message and processes it and any
+// buffered messages that follow it in sequence. Duplicates are only
+// acknowledged.
+func (c *dataChannel) receive(msg ssmMessage) error {
+	if err := c.acknowledge(msg); err != nil {
+		return err
+	}
+
+	switch {
+	case msg.SequenceNumber < c.expectedSeq:
+		return nil
+	case msg.SequenceNumber > c.expectedSeq:
+		c.outOfOrder[msg.SequenceNumber] = msg
+		return nil
+	}
+
+	for {
+		if err := c.process(msg); err != nil {
+			return err
+		}
+		c.expectedSeq++
+
+		next, ok := c.outOfOrder[c.expectedSeq]
+		if !ok {
+			return nil
+		}
+		delete(c.outOfOrder, c.expectedSeq)
+		msg = next
+	}
+}
+
+func (c *dataChannel) acknowledge(msg ssmMessage) error {
+	content, err := json.Marshal(acknowledgeContent{
+		AcknowledgedMessageType:           msg.MessageType,
+		AcknowledgedMessageID:             msg.MessageID.String(),
+		AcknowledgedMessageSequenceNumber: msg.SequenceNumber,
+		IsSequentialMessage:               true,
+	})
+	if err != nil {
+		return err
+	}
+
+	ack := ssmMessage{
+		MessageType:   msgAcknowledge,
+		SchemaVersion: 1,
+		CreatedDate:   time.Now(),
+		Flags:         flagAck,
+		MessageID:     newMessageID(),
+		Payload:       content,
+	}
+	return c.write(ack.marshal())
+}
+
+// process acts on a stream data message received in sequence
+func (c *dataChannel) process(msg ssmMessage) error {
+	switch msg.PayloadType {
+	case payloadOutput:
+		c.markReady()
+		if _, err := c.writer.Write(msg.Payload); err != nil && err != io.ErrClosedPipe {
+			return err
+		}
+
+	case payloadStdErr:
+		os.Stderr.Write(msg.Payload)
+
+	case payloadExitCode:
+		// The agent sends the code as decimal text
+		if code, err := strconv.Atoi(strings.TrimSpace(string(msg.Payload))); err == nil {
+			c.mu.Lock()
+			c.exitCode = code
+			c.mu.Unlock()
+		}
+
+	case payloadHandshakeRequest:
+		return c.handshake(msg.Payload)
+
+	case payloadHandshakeComplete:
+		var complete handshakeComplete
+		if err := json.Unmarshal(msg.Payload, &complete); err == nil && complete.CustomerMessage != "" {
+			fmt.Fprintln(os.Stderr, complete.CustomerMessage)
+		}
+		c.markReady()
+
+	case payloadEncChallengeRequest:
+		return errKMSEncryption
+
+	case payloadFlag:
+		if len(msg.Payload) == 4 && binary.BigEndian.Uint32(msg.Payload) == portFlagConnectToPortError {
+			fmt.Fprintln(os.Stderr, "Warning: the instance could not connect to the remote port; check the SSM agent logs")
+		}
+	}
+	return nil
+}
+
+// handshake answers the agent's handshake request, accepting the session
+// type. KMS encryption is refused, which ends the session.
+func (c *dataChannel) handshake(payload []byte) error {
+	var req handshakeRequest
+	if err := json.Unmarshal(payload, &req); err != nil {
+		return fmt.Errorf("invalid handshake request: %w", err)
+	}
+
+	resp := handshakeResponse{ClientVersion: ssmClientVersion, Errors: []string{}}
+	var failure error
+	for _, action := range req.RequestedClientActions {
+		switch action.ActionType {
+		case actionSessionType:
+			var params sessionTypeParameters
+			if err := json.Unmarshal(action.ActionParameters, &params); err != nil {
+				return fmt.Errorf("invalid session type in handshake: %w", err)
+			}
+			c.sessionType = params.SessionType
+			resp.ProcessedClientActions = append(resp.ProcessedClientActions,
+				processedClientAction{ActionType: action.ActionType, ActionStatus: actionStatusSuccess})
+
+		case actionKMSEncryption:
+			failure = errKMSEncryption
+			resp.ProcessedClientActions = append(resp.ProcessedClientActions,
+				processedClientAction{ActionType: action.ActionType, ActionStatus: actionStatusFailed, Error: errKMSEncryption.Error()})
+			resp.Errors = append(resp.Errors, errKMSEncryption.Error())
+
+		default:
+			resp.ProcessedClientActions = append(resp.ProcessedClientActions,
+				processedClientAction{ActionType: action.ActionType, ActionStatus: actionStatusUnsupported, Error: "unsupported action"})
+		}
+	}
+
+	data, err := json.Marshal(resp)
+	if err != nil {
+		return err
+	}
+	if err := c.send(payloadHandshakeResponse, data); err != nil {
+		return err
+	}
+	return failure
+}
+
+// resendLoop retransmits unacknowledged input every interval and keeps the
+// websocket alive. The channel fails if the agent doesn't acknowledge a
+// message within timeout.
+func (c *dataChannel) resendLoop(interval, timeout, keepAliveEvery time.Duration) {
+	resend := time.NewTicker(interval)
+	defer resend.Stop()
+	keepAlive := time.NewTicker(keepAliveEvery)
+	defer keepAlive.Stop()
+
+	for {
+		select {
+		case <-c.done:
+			return
+
+		case <-keepAlive.C:
+			if err := c.conn.WriteControl(websocket.PingMessage, nil, time.Now().Add(10*time.Second)); err != nil {
+				c.shutdown(fmt.Errorf("session stream closed: %w", err))
+				return
+			}
+
+		case now := <-resend.C:
+			var due []*unackedMessage
+			expired := false
+			c.mu.Lock()
+			for _, m := range c.unacked {
+				if now.Sub(m.firstSent) > timeout {
+					expired = true
+				}
+				if now.Sub(m.lastSent) >= interval {
+					m.lastSent = now
+					due = append(due, m)
+				}
+			}
+			c.mu.Unlock()
+
+			if expired {
+				c.shutdown(errors.New("the instance stopped acknowledging input"))
+				return
+			}
+
+			sort.Slice(due, func(i, j int) bool { return due[i].seq < due[j].seq })
+			for _, m := range due {
+				if err := c.write(m.data); err != nil {
+					c.shutdown(fmt.Errorf("session stream closed: %w", err))
+					return
+				}
+			}
+		}
+	}
+}
@@ -0,0 +1,383 @@
+package main
+
+import (
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// fakeAgent is a stand-in for the stream service and the SSM agent behind it
+type fakeAgent struct {
+	t    *testing.T
+	conn *websocket.Conn
+	acks []int64
+}
+
+// startFakeAgent serves one session, running agent once the client has
+// opened the data channel. It returns the stream URL and a channel that is
+// closed when agent returns.
+func startFakeAgent(t *testing.T, agent func(a *fakeAgent)) (string, <-chan struct{}) {
+	t.Helper()
+
+	done := make(chan struct{})
+	upgrader := websocket.Upgrader{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		defer close(done)
+		conn, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			t.Errorf("Expected a websocket upgrade, got %v", err)
+			return
+		}
+		defer conn.Close()
+
+		var open openDataChannelInput
+		if err := conn.ReadJSON(&open); err != nil || open.TokenValue != "token" || open.ClientVersion != ssmClientVersion {
+			t.Errorf("Expected the data channel to be opened with the token, got %+v, %v", open, err)
+			return
+		}
+
+		agent(&fakeAgent{t: t, conn: conn})
+	}))
+	t.Cleanup(server.Close)
+
+	return "ws" + strings.TrimPrefix(server.URL, "http"), done
+}
+
+// waitAgent waits for the fake agent to finish
+func waitAgent(t *testing.T, done <-chan struct{}) {
+	t.Helper()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatalf("Expected the fake agent to finish")
+	}
+}
+
+func (a *fakeAgent) send(msg ssmMessage) {
+	if msg.MessageID == (messageID{}) {
+		msg.MessageID = newMessageID()
+	}
+	msg.SchemaVersion = 1
+	msg.CreatedDate = time.Now()
+	if err := a.conn.WriteMessage(websocket.BinaryMessage, msg.marshal()); err != nil {
+		a.t.Errorf("Expected to send %s, got %v", msg.MessageType, err)
+	}
+}
+
+func (a *fakeAgent) output(seq int64, payloadType uint32, payload []byte) {
+	a.send(ssmMessage{MessageType: msgOutputStreamData, SequenceNumber: seq, PayloadType: payloadType, Payload: payload})
+}
+
+func (a *fakeAgent) handshake(seq int64, actions ...requestedClientAction) {
+	payload, _ := json.Marshal(handshakeRequest{AgentVersion: "3.3.0.0", RequestedClientActions: actions})
+	a.output(seq, payloadHandshakeRequest, payload)
+}
+
+func (a *fakeAgent) close(output string) {
+	payload, _ := json.Marshal(channelClosedContent{SessionID: "session-1", Output: output})
+	a.send(ssmMessage{MessageType: msgChannelClosed, Payload: payload})
+}
+
+func (a *fakeAgent) ack(msg ssmMessage) {
+	payload, _ := json.Marshal(acknowledgeContent{
+		AcknowledgedMessageType:           msg.MessageType,
+		AcknowledgedMessageID:             msg.MessageID.String(),
+		AcknowledgedMessageSequenceNumber: msg.SequenceNumber,
+		IsSequentialMessage:               true,
+	})
+	a.send(ssmMessage{MessageType: msgAcknowledge, Flags: flagAck, Payload: payload})
+}
+
+// nextInput returns the next input message, noting the acknowledgements
+// received before it. It runs on the server's goroutine, so a failure is
+// reported with ok false for the agent to return.
+func (a *fakeAgent) nextInput() (msg ssmMessage, ok bool) {
+	for {
+		a.conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+		_, data, err := a.conn.ReadMessage()
+		if err != nil {
+			a.t.Errorf("Expected a message from the client, got %v", err)
+			return msg, false
+		}
+		msg, err = unmarshalSSMMessage(data)
+		if err != nil {
+			a.t.Errorf("Expected a valid message from the client, got %v", err)
+			return msg, false
+		}
+
+		if msg.MessageType == msgAcknowledge {
+			var ack acknowledgeContent
+			json.Unmarshal(msg.Payload, &ack)
+			a.acks = append(a.acks, ack.AcknowledgedMessageSequenceNumber)
+			continue
+		}
+		if msg.MessageType != msgInputStreamData {
+			a.t.Errorf("Expected input, got %s", msg.MessageType)
+			return msg, false
+		}
+		return msg, true
+	}
+}
+
+// acceptHandshake hands shake with the client for a session type and
+// returns the client's response, with ok false if the handshake failed
+func (a *fakeAgent) acceptHandshake(sessionType string) (resp handshakeResponse, ok bool) {
+	params, _ := json.Marshal(sessionTypeParameters{SessionType: sessionType})
+	a.handshake(0, requestedClientAction{ActionType: actionSessionType, ActionParameters: params})
+
+	msg, ok := a.nextInput()
+	if !ok {
+		return resp, false
+	}
+	if msg.PayloadType != payloadHandshakeResponse || msg.SequenceNumber != 0 || msg.Flags != flagSyn {
+		a.t.Errorf("Expected the handshake response as the first input, got %+v", msg)
+		return resp, false
+	}
+	a.ack(msg)
+
+	if err := json.Unmarshal(msg.Payload, &resp); err != nil {
+		a.t.Errorf("Expected a JSON handshake response, got %v", err)
+		return resp, false
+	}
+
+	complete, _ := json.Marshal(handshakeComplete{})
+	a.output(1, payloadHandshakeComplete, complete)
+	return resp, true
+}
+
+func TestDataChannelShellSession(t *testing.T) {
+	defer func(interval time.Duration) { resendInterval = interval }(resendInterval)
+	resendInterval = 20 * time.Millisecond
+
+	var resp handshakeResponse
+	var resent bool
+	url, agentDone := startFakeAgent(t, func(a *fakeAgent) {
+		var ok bool
+		if resp, ok = a.acceptHandshake(sessionTypeShell); !ok {
+			return
+		}
+
+		// Out of order and duplicated output is delivered once, in order
+		a.output(3, payloadOutput, []byte("world"))
+		a.output(2, payloadOutput, []byte("hello "))
+		a.output(2, payloadOutput, []byte("hello "))
+
+		// Unacknowledged input is sent again
+		first, ok := a.nextInput()
+		if !ok {
+			return
+		}
+		again, ok := a.nextInput()
+		if !ok {
+			return
+		}
+		resent = first.SequenceNumber == again.SequenceNumber && string(again.Payload) == "ls\r"
+		a.ack(again)
+
+		a.output(4, payloadExitCode, []byte("3"))
+		a.close("Exiting session with sessionId: session-1.")
+	})
+
+	ctx := context.Background()
+	ch, err := openDataChannel(ctx, url, "token")
+	if err != nil {
+		t.Fatalf("Expected the data channel to open, got %v", err)
+	}
+	defer ch.Close()
+
+	sessionType, err := ch.waitReady(ctx)
+	if err != nil || sessionType != sessionTypeShell {
+		t.Fatalf("Expected a %s session, got %q, %v", sessionTypeShell, sessionType, err)
+	}
+
+	if _, err := ch.Write([]byte("ls\r")); err != nil {
+		t.Fatalf("Expected the input to be sent, got %v", err)
+	}
+
+	output, err := io.ReadAll(ch)
+	if err != nil {
+		t.Fatalf("Expected the output to end cleanly, got %v", err)
+	}
+	if string(output) != "hello world" {
+		t.Errorf("Expected %q, got %q", "hello world", output)
+	}
+	waitAgent(t, agentDone)
+	if !resent {
+		t.Errorf("Expected unacknowledged input to be retransmitted")
+	}
+	if len(resp.ProcessedClientActions) != 1 || resp.ProcessedClientActions[0].ActionStatus != actionStatusSuccess {
+		t.Errorf("Expected the session type to be accepted, got %+v", resp)
+	}
+	if got := ch.closedOutput(); got != "Exiting session with sessionId: session-1." {
+		t.Errorf("Expected the channel closed message, got %q", got)
+	}
+	if code := ch.exitStatus(); code != 3 {
+		t.Errorf("Expected the exit code 3, got %d", code)
+	}
+}
+
+func TestDataChannelRejectsKMSEncryption(t *testing.T) {
+	var resp handshakeResponse
+	url, agentDone := startFakeAgent(t, func(a *fakeAgent) {
+		a.handshake(0, requestedClientAction{ActionType: actionKMSEncryption, ActionParameters: json.RawMessage(`{"KMSKeyId":"alias/ssm"}`)})
+		if msg, ok := a.nextInput(); ok {
+			json.Unmarshal(msg.Payload, &resp)
+		}
+	})
+
+	ctx := context.Background()
+	ch, err := openDataChannel(ctx, url, "token")
+	if err != nil {
+		t.Fatalf("Expected the data channel to open, got %v", err)
+	}
+	defer ch.Close()
+
+	if _, err := ch.waitReady(ctx); !errors.Is(err, errKMSEncryption) {
+		t.Errorf("Expected errKMSEncryption, got %v", err)
+	}
+	waitAgent(t, agentDone)
+	if len(resp.ProcessedClientActions) != 1 || resp.ProcessedClientActions[0].ActionStatus != actionStatusFailed {
+		t.Errorf("Expected KMS encryption to be refused, got %+v", resp)
+	}
+}
+
+func TestAttachStdio(t *testing.T) {
+	var input []byte
+	var flag uint32
+	url, agentDone := startFakeAgent(t, func(a *fakeAgent) {
+		if _, ok := a.acceptHandshake(sessionTypePort); !ok {
+			return
+		}
+		a.output(2, payloadOutput, []byte("SSH-2.0-OpenSSH_9.6\r\n"))
+
+		for {
+			msg, ok := a.nextInput()
+			if !ok {
+				return
+			}
+			a.ack(msg)
+			if msg.PayloadType == payloadFlag {
+				flag = binary.BigEndian.Uint32(msg.Payload)
+				break
+			}
+			input = append(input, msg.Payload...)
+		}
+		a.close("")
+	})
+
+	ctx := context.Background()
+	ch, err := openDataChannel(ctx, url, "token")
+	if err != nil {
+		t.Fatalf("Expected the data channel to open, got %v", err)
+	}
+	defer ch.Close()
+
+	if sessionType, err := ch.waitReady(ctx); err != nil || sessionType != sessionTypePort {
+		t.Fatalf("Expected a port session, got %q, %v", sessionType, err)
+	}
+
+	var out strings.Builder
+	if err := attachStdio(ch, strings.NewReader("SSH-2.0-Go\r\n"), &out); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if out.String() != "SSH-2.0-OpenSSH_9.6\r\n" {
+		t.Errorf("Expected the remote banner, got %q", out.String())
+	}
+	waitAgent(t, agentDone)
+	if string(input) != "SSH-2.0-Go\r\n" {
+		t.Errorf("Expected stdin to be sent, got %q", input)
+	}
+	if flag != portFlagTerminateSession {
+		t.Errorf("Expected the session to be terminated at the end of stdin, got flag %d", flag)
+	}
+}
+
+func TestServeLocalPort(t *testing.T) {
+	port, err := freeLocalPort()
+	if err != nil {
+		t.Fatalf("Expected a free port, got %v", err)
+	}
+
+	var flag uint32
+	url, agentDone := startFakeAgent(t, func(a *fakeAgent) {
+		if _, ok := a.acceptHandshake(sessionTypePort); !ok {
+			return
+		}
+
+		msg, ok := a.nextInput()
+		if !ok {
+			return
+		}
+		a.ack(msg)
+		if string(msg.Payload) != "ping" {
+			t.Errorf("Expected the connection's data, got %q", msg.Payload)
+		}
+		a.output(2, payloadOutput, []byte("pong"))
+
+		if msg, ok = a.nextInput(); !ok {
+			return
+		}
+		a.ack(msg)
+		if msg.PayloadType == payloadFlag {
+			flag = binary.BigEndian.Uint32(msg.Payload)
+		}
+		a.close("")
+	})
+
+	ctx := context.Background()
+	ch, err := openDataChannel(ctx, url, "token")
+	if err != nil {
+		t.Fatalf("Expected the data channel to open, got %v", err)
+	}
+	defer ch.Close()
+
+	if _, err := ch.waitReady(ctx); err != nil {
+		t.Fatalf("Expected the session to be ready, got %v", err)
+	}
+
+	served := make(chan error, 1)
+	go func() { served <- serveLocalPort(ch, port) }()
+
+	var conn net.Conn
+	for i := 0; i < 50; i++ {
+		if conn, err = net.Dial("tcp", net.JoinHostPort("127.0.0.1", strconv.Itoa(port))); err == nil {
+			break
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+	if err != nil {
+		t.Fatalf("Expected to connect to the local port, got %v", err)
+	}
+
+	conn.Write([]byte("ping"))
+	reply := make([]byte, 4)
+	conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	if _, err := io.ReadFull(conn, reply); err != nil || string(reply) != "pong" {
+		t.Errorf("Expected the remote reply, got %q, %v", reply, err)
+	}
+	conn.Close()
+
+	select {
+	case err := <-served:
+		if err != nil {
+			t.Errorf("Expected the session to end cleanly, got %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatalf("Expected serveLocalPort to return when the session closed")
+	}
+	waitAgent(t, agentDone)
+	if flag != portFlagDisconnectToPort {
+		t.Errorf("Expected the agent to be told the connection closed, got flag %d", flag)
+	}
+}
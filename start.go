@@ -0,0 +1,138 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/AlecAivazis/survey/v2"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ec2"
+	"github.com/aws/aws-sdk-go-v2/service/ec2/types"
+	"github.com/aws/aws-sdk-go-v2/service/ssm"
+	ssmtypes "github.com/aws/aws-sdk-go-v2/service/ssm/types"
+)
+
+// agentPollInterval is how often the SSM agent status is checked after a start
+const agentPollInterval = 5 * time.Second
+
+// ensureInstanceRunning starts a stopped instance when --start is given or the
+// user agrees, then waits until it is running and its SSM agent is online.
+// Instances that are already running are returned unchanged.
+func ensureInstanceRunning(ctx context.Context, cfg aws.Config, instance Instance) (Instance, error) {
+	switch types.InstanceStateName(instance.State) {
+	case types.InstanceStateNameRunning:
+		return instance, nil
+	case types.InstanceStateNameStopped:
+		start, err := confirmStart(instance)
+		if err != nil {
+			return instance, err
+		}
+		if !start {
+			return instance, fmt.Errorf("instance %s is stopped; use --start to start it before connecting", instance.ID)
+		}
+	case types.InstanceStateNamePending:
+		// Already starting, just wait for it
+	default:
+		return instance, fmt.Errorf("instance %s is not in running state (current state: %s)", instance.ID, instance.State)
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, startTimeout)
+	defer cancel()
+
+	ec2Client := ec2.NewFromConfig(cfg)
+
+	if instance.State == string(types.InstanceStateNameStopped) {
+		fmt.Fprintf(os.Stderr, "Starting instance %s (%s)...\n", instance.Name, instance.ID)
+		_, err := ec2Client.StartInstances(ctx, &ec2.StartInstancesInput{
+			InstanceIds: []string{instance.ID},
+		})
+		if err != nil {
+			return instance, fmt.Errorf("failed to start instance: %w", err)
+		}
+	}
+
+	fmt.Fprintln(os.Stderr, "Waiting for the instance to enter the running state...")
+	waiter := ec2.NewInstanceRunningWaiter(ec2Client)
+	output, err := waiter.WaitForOutput(ctx, &ec2.DescribeInstancesInput{
+		InstanceIds: []string{instance.ID},
+	}, startTimeout)
+	if err != nil {
+		return instance, fmt.Errorf("instance %s did not reach the running state: %w", instance.ID, err)
+	}
+
+	instance.State = string(types.InstanceStateNameRunning)
+	for _, reservation := range output.Reservations {
+		for _, started := range reservation.Instances {
+			// Public IPs are usually reassigned on start
+			instance.PublicIP = aws.ToString(started.PublicIpAddress)
+		}
+	}
+
+	if err := waitForSSMAgent(ctx, cfg, &instance); err != nil {
+		return instance, err
+	}
+
+	fmt.Fprintf(os.Stderr, "Instance %s is running and its SSM agent is online.\n", instance.ID)
+	return instance, nil
+}
+
+// confirmStart decides whether a stopped instance should be started: --start
+// always does, otherwise the user is asked when a terminal is attached.
+func confirmStart(instance Instance) (bool, error) {
+	if startStopped {
+		return true, nil
+	}
+	if !isInteractive() {
+		return false, nil
+	}
+
+	start := false
+	prompt := &survey.Confirm{
+		Message: fmt.Sprintf("Instance %s (%s) is stopped. Start it?", instance.Name, instance.ID),
+		Default: true,
+	}
+	if err := survey.AskOne(prompt, &start); err != nil {
+		return false, err
+	}
+	return start, nil
+}
+
+// waitForSSMAgent polls Systems Manager until the instance's agent reports
+// Online, updating the instance's SSM fields as it goes.
+func waitForSSMAgent(ctx context.Context, cfg aws.Config, instance *Instance) error {
+	ssmClient := ssm.NewFromConfig(cfg)
+	started := time.Now()
+
+	fmt.Fprintln(os.Stderr, "Waiting for the SSM agent to come online...")
+
+	for {
+		output, err := ssmClient.DescribeInstanceInformation(ctx, &ssm.DescribeInstanceInformationInput{
+			Filters: []ssmtypes.InstanceInformationStringFilter{
+				{Key: aws.String("InstanceIds"), Values: []string{instance.ID}},
+			},
+		})
+		if err != nil && !errors.Is(err, context.DeadlineExceeded) {
+			return fmt.Errorf("failed to check SSM agent status: %w", err)
+		}
+		if err == nil && len(output.InstanceInformationList) > 0 {
+			item := output.InstanceInformationList[0]
+			instance.PingStatus = string(item.PingStatus)
+			instance.AgentVersion = aws.ToString(item.AgentVersion)
+			instance.PlatformName = aws.ToString(item.PlatformName)
+			instance.LastPingTime = aws.ToTime(item.LastPingDateTime)
+			if item.PingStatus == ssmtypes.PingStatusOnline {
+				return nil
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("SSM agent on %s did not come online within %s (last status: %s)", instance.ID, startTimeout, instance.PingStatus)
+		case <-time.After(agentPollInterval):
+			fmt.Fprintf(os.Stderr, "  still waiting for the SSM agent (%s elapsed)\n", time.Since(started).Round(time.Second))
+		}
+	}
+}
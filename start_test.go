@@ -0,0 +1,50 @@
+package main
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+)
+
+func TestEnsureInstanceRunningWithoutStarting(t *testing.T) {
+	withoutTerminal(t)
+	ctx := context.Background()
+	startStopped = false
+
+	running := Instance{ID: "i-1", State: "running"}
+	got, err := ensureInstanceRunning(ctx, aws.Config{}, running)
+	if err != nil {
+		t.Fatalf("Unexpected error for a running instance: %v", err)
+	}
+	if got.ID != running.ID || got.State != "running" {
+		t.Errorf("Expected the running instance back unchanged, got %+v", got)
+	}
+
+	// Without a terminal, a stopped instance needs --start
+	_, err = ensureInstanceRunning(ctx, aws.Config{}, Instance{ID: "i-2", State: "stopped"})
+	if err == nil || !strings.Contains(err.Error(), "--start") {
+		t.Errorf("Expected an error suggesting --start, got %v", err)
+	}
+
+	_, err = ensureInstanceRunning(ctx, aws.Config{}, Instance{ID: "i-3", State: "stopping"})
+	if err == nil {
+		t.Error("Expected an error for a stopping instance")
+	}
+}
+
+func TestConfirmStart(t *testing.T) {
+	withoutTerminal(t)
+	defer func() { startStopped = false }()
+
+	startStopped = true
+	if start, err := confirmStart(Instance{ID: "i-1"}); err != nil || !start {
+		t.Errorf("Expected --start to start without asking, got %v, %v", start, err)
+	}
+
+	startStopped = false
+	if start, err := confirmStart(Instance{ID: "i-1"}); err != nil || start {
+		t.Errorf("Expected no start without a terminal, got %v, %v", start, err)
+	}
+}
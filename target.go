@@ -0,0 +1,78 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path"
+	"strings"
+
+	"golang.org/x/term"
+)
+
+// matchInstances returns the instances matching target. The target is tried
+// as an exact instance ID first, then as an exact Name tag, and finally as a
+// glob pattern against the Name tag and instance ID, where * also matches "/".
+func matchInstances(instances []Instance, target string) ([]Instance, error) {
+	for _, inst := range instances {
+		if inst.ID == target {
+			return []Instance{inst}, nil
+		}
+	}
+
+	var matches []Instance
+	for _, inst := range instances {
+		if inst.Name == target {
+			matches = append(matches, inst)
+		}
+	}
+	if len(matches) > 0 || !isGlobPattern(target) {
+		return matches, nil
+	}
+
+	if _, err := path.Match(target, ""); err != nil {
+		return nil, fmt.Errorf("invalid pattern %q: %w", target, err)
+	}
+	for _, inst := range instances {
+		if globMatch(target, inst.Name) || globMatch(target, inst.ID) {
+			matches = append(matches, inst)
+		}
+	}
+
+	return matches, nil
+}
+
+// resolveInstance narrows instances down to a single instance for target,
+// falling back to the interactive picker only when several instances match.
+func resolveInstance(instances []Instance, target string) (Instance, error) {
+	matches, err := matchInstances(instances, target)
+	if err != nil {
+		return Instance{}, err
+	}
+
+	switch {
+	case len(matches) == 0:
+		return Instance{}, fmt.Errorf("no instance matches %q", target)
+	case len(matches) == 1:
+		return matches[0], nil
+	case !isInteractive():
+		var ids []string
+		for _, inst := range matches {
+			ids = append(ids, inst.ID)
+		}
+		return Instance{}, fmt.Errorf("%q matches %d instances (%s); use a more specific target or run from a terminal to pick one",
+			target, len(matches), strings.Join(ids, ", "))
+	}
+
+	return selectInstance(matches)
+}
+
+func isGlobPattern(s string) bool {
+	return strings.ContainsAny(s, "*?[")
+}
+
+// isInteractive reports whether stdin is attached to a terminal. Character
+// devices such as /dev/null are not terminals. It is a variable so tests
+// don't depend on how they were started.
+var isInteractive = func() bool {
+	return term.IsTerminal(int(os.Stdin.Fd()))
+}
@@ -0,0 +1,82 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestMatchInstances(t *testing.T) {
+	instances := []Instance{
+		{ID: "i-0123456789abcdef0", Name: "web-1"},
+		{ID: "i-0123456789abcdef1", Name: "web-2"},
+		{ID: "i-0123456789abcdef2", Name: "db-1"},
+		{ID: "i-0123456789abcdef3", Name: "web-1"},
+		{ID: "i-0123456789abcdef4", Name: "team/prod-api"},
+	}
+
+	tests := []struct {
+		name    string
+		target  string
+		wantIDs []string
+		wantErr bool
+	}{
+		{"Instance ID", "i-0123456789abcdef2", []string{"i-0123456789abcdef2"}, false},
+		{"Exact name", "db-1", []string{"i-0123456789abcdef2"}, false},
+		{"Duplicate name", "web-1", []string{"i-0123456789abcdef0", "i-0123456789abcdef3"}, false},
+		{"Glob on name", "web-*", []string{"i-0123456789abcdef0", "i-0123456789abcdef1", "i-0123456789abcdef3"}, false},
+		{"Glob on ID", "i-*f1", []string{"i-0123456789abcdef1"}, false},
+		{"Glob across a slash", "team/*", []string{"i-0123456789abcdef4"}, false},
+		{"Glob around a slash", "*prod*", []string{"i-0123456789abcdef4"}, false},
+		{"No match", "cache-1", nil, false},
+		{"No glob match", "cache-*", nil, false},
+		{"Invalid pattern", "web-[", nil, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			matches, err := matchInstances(instances, tt.target)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Expected error=%v, got %v", tt.wantErr, err)
+			}
+			if len(matches) != len(tt.wantIDs) {
+				t.Fatalf("Expected %d matches, got %d", len(tt.wantIDs), len(matches))
+			}
+			for i, inst := range matches {
+				if inst.ID != tt.wantIDs[i] {
+					t.Errorf("Expected match %d to be %s, got %s", i, tt.wantIDs[i], inst.ID)
+				}
+			}
+		})
+	}
+}
+
+// withoutTerminal makes isInteractive report no terminal for one test
+func withoutTerminal(t *testing.T) {
+	interactive := isInteractive
+	isInteractive = func() bool { return false }
+	t.Cleanup(func() { isInteractive = interactive })
+}
+
+func TestResolveInstance(t *testing.T) {
+	withoutTerminal(t)
+	instances := []Instance{
+		{ID: "i-0123456789abcdef0", Name: "web-1"},
+		{ID: "i-0123456789abcdef1", Name: "web-2"},
+	}
+
+	inst, err := resolveInstance(instances, "web-2")
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if inst.ID != "i-0123456789abcdef1" {
+		t.Errorf("Expected i-0123456789abcdef1, got %s", inst.ID)
+	}
+
+	if _, err := resolveInstance(instances, "db-*"); err == nil {
+		t.Error("Expected an error when nothing matches")
+	}
+
+	if _, err := resolveInstance(instances, "web-*"); err == nil || !strings.Contains(err.Error(), "matches 2 instances") {
+		t.Errorf("Expected an ambiguity error without a terminal, got %v", err)
+	}
+}
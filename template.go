@@ -0,0 +1,83 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"reflect"
+	"strings"
+	"text/template"
+)
+
+// formatEscapes lets --format use \t and \n, which shells don't expand inside quotes
+var formatEscapes = strings.NewReplacer(`\t`, "\t", `\n`, "\n")
+
+// listTemplate is a parsed --format template, executed once per listed item.
+// The tag helpers read the tags of the item being executed.
+type listTemplate struct {
+	tmpl *template.Template
+	tags map[string]string
+}
+
+// parseListTemplate parses a --format template. Every exported field of the
+// listed struct is available (e.g. {{.ID}}, {{.Tags.Env}}), plus:
+//
+//	tag "Env"        value of a tag of the item, empty if unset
+//	tags             the item's tags as sorted key=value strings
+//	default "x" .V   .V, or "x" if .V is empty (also {{.V | default "x"}})
+//	join "," .List   elements joined with a separator
+//	upper, lower     change the case of a string
+func parseListTemplate(text string) (*listTemplate, error) {
+	lt := &listTemplate{}
+
+	funcs := template.FuncMap{
+		"tag": func(key string) string {
+			return lt.tags[key]
+		},
+		"tags": func() []string {
+			return tagPairs(lt.tags)
+		},
+		"default": defaultValue,
+		"join": func(sep string, elems []string) string {
+			return strings.Join(elems, sep)
+		},
+		"upper": strings.ToUpper,
+		"lower": strings.ToLower,
+	}
+
+	text = formatEscapes.Replace(text)
+	if !strings.HasSuffix(text, "\n") {
+		text += "\n"
+	}
+
+	tmpl, err := template.New("format").Funcs(funcs).Option("missingkey=zero").Parse(text)
+	if err != nil {
+		return nil, err
+	}
+	lt.tmpl = tmpl
+	return lt, nil
+}
+
+// execute writes item using the template, with tags for the tag helpers
+func (lt *listTemplate) execute(w io.Writer, item any, tags map[string]string) error {
+	lt.tags = tags
+	return lt.tmpl.Execute(w, item)
+}
+
+// defaultValue returns value, or def when value is empty or the zero value
+func defaultValue(def, value any) any {
+	v := reflect.ValueOf(value)
+	if !v.IsValid() || v.IsZero() {
+		return def
+	}
+	return value
+}
+
+// writeTemplate writes each item with the template
+func writeTemplate[T any](w io.Writer, lt *listTemplate, items []T, tagsOf func(T) map[string]string) error {
+	for _, item := range items {
+		if err := lt.execute(w, item, tagsOf(item)); err != nil {
+			return fmt.Errorf("executing --format: %w", err)
+		}
+	}
+	return nil
+}
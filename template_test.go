@@ -0,0 +1,81 @@
+package main
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestListTemplate(t *testing.T) {
+	instances := []Instance{
+		{ID: "i-1", Name: "web-1", PrivateIP: "10.0.0.1", Tags: map[string]string{"Name": "web-1", "Env": "prod"}},
+		{ID: "i-2", PrivateIP: "10.0.0.2"},
+	}
+
+	tests := []struct {
+		name     string
+		format   string
+		expected string
+	}{
+		{"fields", "{{.PrivateIP}} {{.ID}}", "10.0.0.1 i-1\n10.0.0.2 i-2\n"},
+		{"escapes", `{{.ID}}\t{{.PrivateIP}}`, "i-1\t10.0.0.1\ni-2\t10.0.0.2\n"},
+		{"trailing newline kept", "{{.ID}}\n", "i-1\ni-2\n"},
+		{"tag", `{{.ID}}={{tag "Env"}}`, "i-1=prod\ni-2=\n"},
+		{"tag field", `{{.ID}}={{.Tags.Env}}`, "i-1=prod\ni-2=\n"},
+		{"default", `{{.Name | default "unnamed"}}`, "web-1\nunnamed\n"},
+		{"join tags", `{{join ";" tags}}`, "Env=prod;Name=web-1\n\n"},
+		{"upper", `{{upper (tag "Env" | default "none")}}`, "PROD\nNONE\n"},
+		{"lower", `{{lower .ID}}`, "i-1\ni-2\n"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tmpl, err := parseListTemplate(tt.format)
+			if err != nil {
+				t.Fatalf("Expected the template to parse, got %v", err)
+			}
+
+			var buf bytes.Buffer
+			if err := writeTemplate(&buf, tmpl, instances, func(inst Instance) map[string]string { return inst.Tags }); err != nil {
+				t.Fatalf("Expected no error, got %v", err)
+			}
+			if buf.String() != tt.expected {
+				t.Errorf("Expected %q, got %q", tt.expected, buf.String())
+			}
+		})
+	}
+}
+
+func TestListTemplateRDS(t *testing.T) {
+	instances := []RDSInstance{{Identifier: "orders", Endpoint: "orders.example.com", Port: 5432}}
+
+	tmpl, err := parseListTemplate("{{.Endpoint}}:{{.Port}} {{.EngineVersion | default \"?\"}}")
+	if err != nil {
+		t.Fatalf("Expected the template to parse, got %v", err)
+	}
+
+	var buf bytes.Buffer
+	if err := writeTemplate(&buf, tmpl, instances, func(inst RDSInstance) map[string]string { return inst.Tags }); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if expected := "orders.example.com:5432 ?\n"; buf.String() != expected {
+		t.Errorf("Expected %q, got %q", expected, buf.String())
+	}
+}
+
+func TestListTemplateErrors(t *testing.T) {
+	if _, err := parseListTemplate("{{.ID"); err == nil {
+		t.Errorf("Expected a parse error for an unclosed action")
+	}
+	if _, err := parseListTemplate("{{nosuchfunc .ID}}"); err == nil {
+		t.Errorf("Expected a parse error for an unknown function")
+	}
+
+	tmpl, err := parseListTemplate("{{.NoSuchField}}")
+	if err != nil {
+		t.Fatalf("Expected the template to parse, got %v", err)
+	}
+	var buf bytes.Buffer
+	if err := writeTemplate(&buf, tmpl, []Instance{{ID: "i-1"}}, func(inst Instance) map[string]string { return nil }); err == nil {
+		t.Errorf("Expected an error for an unknown field")
+	}
+}